@@ -0,0 +1,111 @@
+package activity
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is the outcome of one notification's delivery
+type Record struct {
+	ID      string    `json:"id"`
+	Event   string    `json:"event"`
+	Subject string    `json:"subject"`
+	Sinks   []string  `json:"sinks"`
+	At      time.Time `json:"at"`
+	// Error is set when at least one sink failed
+	Error string `json:"error,omitempty"`
+	// Muted is set when the event was muted and nothing was sent
+	Muted bool `json:"muted,omitempty"`
+}
+
+// Status summarises the service for operators
+type Status struct {
+	Started       time.Time            `json:"started"`
+	QueueDepth    int                  `json:"queue_depth"`
+	QueueCapacity int                  `json:"queue_capacity"`
+	Delivered     int                  `json:"delivered"`
+	Failed        int                  `json:"failed"`
+	DeadLetters   int                  `json:"dead_letters"`
+	LastDelivery  time.Time            `json:"last_delivery"`
+	LastFailure   time.Time            `json:"last_failure"`
+	Sinks         []string             `json:"sinks"`
+	Muted         map[string]time.Time `json:"muted"`
+}
+
+// Log keeps delivery counters since start and the most recent records in memory
+type Log struct {
+	mu           sync.Mutex
+	started      time.Time
+	size         int
+	recent       []Record
+	delivered    int
+	failed       int
+	lastDelivery time.Time
+	lastFailure  time.Time
+}
+
+// NewLog creates a log remembering the last size records
+func NewLog(size int) *Log {
+	if size < 1 {
+		size = 1
+	}
+	return &Log{started: time.Now(), size: size}
+}
+
+// Add records a delivery outcome
+func (l *Log) Add(record Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case record.Muted:
+	case record.Error != "":
+		l.failed++
+		l.lastFailure = record.At
+	default:
+		l.delivered++
+		l.lastDelivery = record.At
+	}
+
+	l.recent = append(l.recent, record)
+	if len(l.recent) > l.size {
+		l.recent = append([]Record(nil), l.recent[len(l.recent)-l.size:]...)
+	}
+}
+
+// Recent returns up to count records, newest first, whose event matches
+// the pattern ("media.*", case-insensitive; empty matches every event)
+func (l *Log) Recent(pattern string, count int) []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []Record
+	for i := len(l.recent) - 1; i >= 0 && len(records) < count; i-- {
+		if pattern == "" || Matches(pattern, l.recent[i].Event) {
+			records = append(records, l.recent[i])
+		}
+	}
+	return records
+}
+
+// Status returns the counters; callers fill in the queue, dead letter,
+// sink and mute details they own
+func (l *Log) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Status{
+		Started:      l.started,
+		Delivered:    l.delivered,
+		Failed:       l.failed,
+		LastDelivery: l.lastDelivery,
+		LastFailure:  l.lastFailure,
+	}
+}
+
+// Matches reports whether event matches the case-insensitive glob pattern
+func Matches(pattern, event string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(event))
+	return err == nil && ok
+}
@@ -0,0 +1,79 @@
+package activity
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jellynotifier/store"
+)
+
+// MutesFile is the name of the mute file inside the data directory
+const MutesFile = "mutes.json"
+
+// Mutes silences event types until a deadline. They are persisted so a
+// restart does not end a mute early.
+type Mutes struct {
+	until *store.Map[time.Time]
+}
+
+// OpenMutes loads the mutes from dir
+func OpenMutes(dir string) (*Mutes, error) {
+	until, err := store.Open[time.Time](filepath.Join(dir, MutesFile))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] [ACTIVITY] Loaded %d mute(s)", until.Len())
+	return &Mutes{until: until}, nil
+}
+
+// Mute silences events matching pattern until the given time, or lifts
+// the mute when until is not in the future
+func (m *Mutes) Mute(pattern string, until time.Time) error {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return fmt.Errorf("event is required")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid event pattern %q: %v", pattern, err)
+	}
+
+	if !until.After(time.Now()) {
+		log.Printf("[DEBUG] [ACTIVITY] Unmuting %s", pattern)
+		_, err := m.until.Delete(pattern)
+		return err
+	}
+	log.Printf("[DEBUG] [ACTIVITY] Muting %s until %s", pattern, until.Format(time.RFC3339))
+	return m.until.Put(pattern, until)
+}
+
+// Muted reports whether the event is muted and until when
+func (m *Mutes) Muted(event string) (time.Time, bool) {
+	now := time.Now()
+	var latest time.Time
+	for pattern, until := range m.Active() {
+		if until.After(now) && Matches(pattern, event) && until.After(latest) {
+			latest = until
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// Active returns the mutes that have not expired yet and drops the others
+func (m *Mutes) Active() map[string]time.Time {
+	now := time.Now()
+	if _, err := m.until.DeleteFunc(func(_ string, until time.Time) bool { return !until.After(now) }); err != nil {
+		log.Printf("[ERROR] [ACTIVITY] Failed to drop expired mutes: %v", err)
+	}
+
+	active := make(map[string]time.Time)
+	for _, pattern := range m.until.Keys() {
+		if until, ok := m.until.Get(pattern); ok {
+			active[pattern] = until
+		}
+	}
+	return active
+}
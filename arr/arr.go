@@ -0,0 +1,165 @@
+// Package arr adapts Sonarr and Radarr webhook payloads into events, with a
+// shared event taxonomy for both applications.
+package arr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jellynotifier/events"
+	"jellynotifier/models"
+)
+
+// kinds maps the *arr event types onto the event names used for routing,
+// templates and colours. Download is refined to download.upgraded when the
+// file replaces an existing one. Types not listed become "arr.<snake_case type>".
+var kinds = map[string]string{
+	"Grab":                      "download.grabbed",
+	"Download":                  "download.completed",
+	"Upgrade":                   "download.upgraded",
+	"ManualInteractionRequired": "download.manual_required",
+	"Rename":                    "library.renamed",
+	"SeriesAdd":                 "library.added",
+	"MovieAdded":                "library.added",
+	"SeriesDelete":              "library.deleted",
+	"MovieDelete":               "library.deleted",
+	"EpisodeFileDelete":         "file.deleted",
+	"MovieFileDelete":           "file.deleted",
+	"Health":                    "health.issue",
+	"HealthIssue":               "health.issue",
+	"HealthRestored":            "health.restored",
+	"ApplicationUpdate":         "application.updated",
+	"Test":                      "arr.test",
+}
+
+// Kind returns the event name for an *arr event type
+func Kind(eventType string, upgrade bool) string {
+	if eventType == "Download" && upgrade {
+		return kinds["Upgrade"]
+	}
+	if kind, ok := kinds[eventType]; ok {
+		return kind
+	}
+	return "arr." + events.SnakeCase(eventType)
+}
+
+// detect reports whether a request came from the application whose
+// User-Agent starts with agent, or whose payload has an eventType and the
+// given media key
+func detect(header http.Header, body []byte, agent, mediaKey string) bool {
+	if strings.HasPrefix(header.Get("User-Agent"), agent) {
+		return true
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return false
+	}
+	_, hasEventType := probe["eventType"]
+	_, hasMedia := probe[mediaKey]
+	return hasEventType && hasMedia
+}
+
+// applicationEvent fills the subject and details of the events that concern
+// the application rather than media: health checks, updates and tests. It
+// reports false for any other event type.
+func applicationEvent(event *events.Event, app, eventType string, health models.ArrHealth, update models.ArrUpdate) bool {
+	switch eventType {
+	case "Health", "HealthIssue":
+		event.Title = fmt.Sprintf("%s health issue: %s", app, events.FirstNonEmpty(health.Message, health.Type))
+	case "HealthRestored":
+		event.Title = fmt.Sprintf("%s health restored: %s", app, events.FirstNonEmpty(health.Message, health.Type))
+	case "ApplicationUpdate":
+		event.Title = fmt.Sprintf("%s updated to %s", app, events.FirstNonEmpty(update.NewVersion, "a new version"))
+		event.Body = health.Message
+	case "Test":
+		event.Title = fmt.Sprintf("Test notification from %s", app)
+	default:
+		return false
+	}
+
+	event.SetAttr("Level", health.Level)
+	event.SetAttr("Check", health.Type)
+	event.SetAttr("Wiki", health.WikiURL)
+	event.SetAttr("Previous version", update.PreviousVersion)
+	return true
+}
+
+// releaseAttributes adds the quality, group, indexer, size and download
+// client of a release
+func releaseAttributes(event *events.Event, download models.ArrDownload) {
+	release := download.Release
+	event.SetAttr("Quality", quality(release.Quality, release.QualityVersion))
+	event.SetAttr("Release group", release.ReleaseGroup)
+	event.SetAttr("Indexer", release.Indexer)
+	event.SetAttr("Size", size(release.Size))
+	event.SetAttr("Download client", download.DownloadClient)
+}
+
+// fileAttributes adds the quality, size and path of an imported file. It
+// runs before releaseAttributes so the file's actual quality wins.
+func fileAttributes(event *events.Event, file models.ArrFile) {
+	event.SetAttr("Quality", quality(file.Quality, file.QualityVersion))
+	event.SetAttr("Release group", file.ReleaseGroup)
+	event.SetAttr("Size", size(file.Size))
+	event.SetAttr("File", file.RelativePath)
+}
+
+// addImages adds the poster and backdrop
+func addImages(event *events.Event, images []models.ArrImage) {
+	event.AddImage(events.ImagePoster, coverURL(images, "poster"))
+	event.AddImage(events.ImageBackdrop, coverURL(images, "fanart"))
+}
+
+// coverURL returns the public URL of the image with the given cover type
+func coverURL(images []models.ArrImage, coverType string) string {
+	for _, image := range images {
+		if image.CoverType == coverType && image.RemoteURL != "" {
+			return image.RemoteURL
+		}
+	}
+	return ""
+}
+
+// quality formats a quality name, marking repacks and propers as "v2" etc.
+func quality(name string, version int) string {
+	if name == "" || version <= 1 {
+		return name
+	}
+	return fmt.Sprintf("%s v%d", name, version)
+}
+
+// size formats a byte count as e.g. "1.4 GB", or "" for zero
+func size(bytes int64) string {
+	if bytes <= 0 {
+		return ""
+	}
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTP"[exp])
+}
+
+// id formats a provider ID, or "" when it is unset
+func id(value int) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+// withYear formats "Title (2024)", or just the title when the year is unknown
+func withYear(title string, year int) string {
+	if year <= 0 {
+		return title
+	}
+	return fmt.Sprintf("%s (%d)", title, year)
+}
@@ -0,0 +1,205 @@
+package arr
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jellynotifier/events"
+)
+
+func payload(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file   string
+		kind   string
+		title  string
+		body   string
+		media  events.Media
+		poster string
+		attrs  map[string]string
+	}{
+		{
+			file:   "sonarr_grab.json",
+			kind:   "download.grabbed",
+			title:  "Grabbed: Severance S02E03 - Who Is Alive?",
+			body:   "Severance.S02E03.Who.Is.Alive.2160p.ATVP.WEB-DL.DDP5.1.DV.H.265-FLUX",
+			media:  events.Media{Type: "tv", Title: "Severance", TmdbID: "95396", TvdbID: "371980", ImdbID: "tt11280740", Season: 2, Episodes: []int{3}},
+			poster: "https://artworks.thetvdb.com/banners/posters/371980-1.jpg",
+			attrs:  map[string]string{"Quality": "WEBDL-2160p", "Release group": "FLUX", "Indexer": "NZBgeek", "Size": "7.0 GB", "Download client": "SABnzbd"},
+		},
+		{
+			file:   "sonarr_download.json",
+			kind:   "download.completed",
+			title:  "Downloaded: Severance S02E03-E04",
+			media:  events.Media{Type: "tv", Title: "Severance", TmdbID: "95396", TvdbID: "371980", ImdbID: "tt11280740", Season: 2, Episodes: []int{3, 4}},
+			poster: "https://artworks.thetvdb.com/banners/posters/371980-1.jpg",
+			attrs:  map[string]string{"Quality": "WEBDL-2160p", "Size": "14.0 GB", "Episodes": "2", "File": "Season 02/Severance - S02E03-E04 - WEBDL-2160p.mkv"},
+		},
+		{
+			file:  "sonarr_upgrade.json",
+			kind:  "download.upgraded",
+			title: "Upgraded: The Bear S01E07 - Review",
+			body:  "Carmy and Richie clash during a busy service.",
+			media: events.Media{Type: "tv", Title: "The Bear", TmdbID: "136315", TvdbID: "403245", ImdbID: "tt14452776", Season: 1, Episodes: []int{7}},
+			attrs: map[string]string{"Quality": "Bluray-1080p v2", "Release group": "NTb", "Download client": "qBittorrent"},
+		},
+		{
+			file:  "sonarr_rename.json",
+			kind:  "library.renamed",
+			title: "Renamed files: Severance (2022)",
+			body:  "2 file(s) renamed",
+			media: events.Media{Type: "tv", Title: "Severance", TmdbID: "95396", TvdbID: "371980", ImdbID: "tt11280740"},
+		},
+		{
+			file:  "sonarr_health.json",
+			kind:  "health.issue",
+			title: "Sonarr health issue: Indexers unavailable due to failures for more than 6 hours: NZBgeek",
+			attrs: map[string]string{"Level": "warning", "Check": "IndexerLongTermStatusCheck"},
+		},
+		{
+			file:  "sonarr_update.json",
+			kind:  "application.updated",
+			title: "Sonarr updated to 4.0.10.2544",
+			body:  "Sonarr updated from 4.0.9.2244 to 4.0.10.2544",
+			attrs: map[string]string{"Previous version": "4.0.9.2244"},
+		},
+		{
+			file:   "radarr_grab.json",
+			kind:   "download.grabbed",
+			title:  "Grabbed: Dune: Part Two (2024)",
+			body:   "Dune.Part.Two.2024.2160p.UHD.BluRay.REMUX.DV.HDR.HEVC.TrueHD.Atmos.7.1-FraMeSToR",
+			media:  events.Media{Type: "movie", Title: "Dune: Part Two", TmdbID: "693134", ImdbID: "tt15239678"},
+			poster: "https://image.tmdb.org/t/p/original/8b8R8l88Qje9dn9OE8PY05Nxl1X.jpg",
+			attrs:  map[string]string{"Quality": "Bluray-2160p", "Indexer": "BeyondHD", "Size": "75.0 GB"},
+		},
+		{
+			file:  "radarr_upgrade.json",
+			kind:  "download.upgraded",
+			title: "Upgraded: Dune: Part Two (2024)",
+			body:  "Paul Atreides unites with Chani and the Fremen.",
+			media: events.Media{Type: "movie", Title: "Dune: Part Two", TmdbID: "693134", ImdbID: "tt15239678"},
+			attrs: map[string]string{"Quality": "Remux-2160p", "File": "Dune Part Two (2024) Remux-2160p.mkv"},
+		},
+		{
+			file:  "radarr_rename.json",
+			kind:  "library.renamed",
+			title: "Renamed files: Dune: Part Two (2024)",
+			body:  "1 file(s) renamed",
+			media: events.Media{Type: "movie", Title: "Dune: Part Two", TmdbID: "693134", ImdbID: "tt15239678"},
+		},
+		{
+			file:  "radarr_health.json",
+			kind:  "health.issue",
+			title: "Radarr 4K health issue: No download client is available",
+			attrs: map[string]string{"Level": "error", "Check": "DownloadClientCheck"},
+		},
+		{
+			file:  "radarr_update.json",
+			kind:  "application.updated",
+			title: "Radarr updated to 5.9.1.9070",
+			body:  "Radarr updated from 5.8.3.8933 to 5.9.1.9070",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			var adapter events.Adapter = SonarrAdapter{}
+			header := http.Header{"User-Agent": {"Sonarr/4.0.10.2544"}}
+			if strings.HasPrefix(tt.file, "radarr") {
+				adapter = RadarrAdapter{}
+				header.Set("User-Agent", "Radarr/5.9.1.9070")
+			}
+			body := payload(t, tt.file)
+
+			if !adapter.Detect(header, body) {
+				t.Fatalf("%s adapter does not detect the payload", adapter.Source())
+			}
+			decoded, err := adapter.Decode(header, body)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if len(decoded) != 1 {
+				t.Fatalf("decoded %d events, want 1", len(decoded))
+			}
+			event := decoded[0]
+
+			if event.Source != adapter.Source() || event.Kind != tt.kind {
+				t.Errorf("source and kind = %s, %s; want %s, %s", event.Source, event.Kind, adapter.Source(), tt.kind)
+			}
+			if event.Title != tt.title {
+				t.Errorf("title = %q, want %q", event.Title, tt.title)
+			}
+			if event.Body != tt.body {
+				t.Errorf("body = %q, want %q", event.Body, tt.body)
+			}
+			if !reflect.DeepEqual(event.Media, tt.media) {
+				t.Errorf("media = %+v, want %+v", event.Media, tt.media)
+			}
+			if event.Image() != tt.poster {
+				t.Errorf("poster = %q, want %q", event.Image(), tt.poster)
+			}
+			for name, value := range tt.attrs {
+				if got := event.Attr(name); got != value {
+					t.Errorf("attribute %s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectWithoutUserAgent(t *testing.T) {
+	tests := []struct {
+		file   string
+		sonarr bool
+		radarr bool
+	}{
+		{file: "sonarr_grab.json", sonarr: true},
+		{file: "radarr_grab.json", radarr: true},
+		// Application events carry no media to tell the two apart
+		{file: "sonarr_health.json"},
+	}
+
+	for _, tt := range tests {
+		body := payload(t, tt.file)
+		if got := (SonarrAdapter{}).Detect(http.Header{}, body); got != tt.sonarr {
+			t.Errorf("Sonarr detects %s: %t, want %t", tt.file, got, tt.sonarr)
+		}
+		if got := (RadarrAdapter{}).Detect(http.Header{}, body); got != tt.radarr {
+			t.Errorf("Radarr detects %s: %t, want %t", tt.file, got, tt.radarr)
+		}
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		eventType string
+		upgrade   bool
+		want      string
+	}{
+		{"Download", false, "download.completed"},
+		{"Download", true, "download.upgraded"},
+		{"Grab", true, "download.grabbed"},
+		{"HealthRestored", false, "health.restored"},
+		{"SeriesAdd", false, "library.added"},
+		{"MovieAdded", false, "library.added"},
+		{"SomeFutureEvent", false, "arr.some_future_event"},
+	}
+
+	for _, tt := range tests {
+		if got := Kind(tt.eventType, tt.upgrade); got != tt.want {
+			t.Errorf("Kind(%q, %t) = %q, want %q", tt.eventType, tt.upgrade, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package arr
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"jellynotifier/events"
+	"jellynotifier/models"
+)
+
+// RadarrAdapter converts Radarr webhook payloads into events
+type RadarrAdapter struct{}
+
+// Source implements events.Adapter
+func (RadarrAdapter) Source() string {
+	return "radarr"
+}
+
+// Detect recognises Radarr by its User-Agent, or by an eventType next to a
+// movie for proxies that replace the User-Agent
+func (RadarrAdapter) Detect(header http.Header, body []byte) bool {
+	return detect(header, body, "Radarr/", "movie")
+}
+
+// Decode implements events.Adapter
+func (RadarrAdapter) Decode(header http.Header, body []byte) ([]events.Event, error) {
+	event, err := DecodeRadarr(body)
+	if err != nil {
+		return nil, err
+	}
+	return []events.Event{event}, nil
+}
+
+// DecodeRadarr parses a Radarr webhook payload into an event
+func DecodeRadarr(body []byte) (events.Event, error) {
+	var payload models.RadarrPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return events.Event{}, fmt.Errorf("error decoding Radarr payload: %v", err)
+	}
+	if payload.EventType == "" {
+		return events.Event{}, fmt.Errorf("radarr payload has no eventType")
+	}
+	log.Printf("[DEBUG] [ARR] Decoded Radarr %s for %q", payload.EventType, payload.Movie.Title)
+	return RadarrEvent(payload), nil
+}
+
+// RadarrEvent maps a Radarr payload onto an event: a title per event type,
+// the movie images and IDs, and release and file attributes
+func RadarrEvent(p models.RadarrPayload) events.Event {
+	event := events.Event{
+		Source: "radarr",
+		Kind:   Kind(p.EventType, p.IsUpgrade),
+		Type:   p.EventType,
+	}
+	addImages(&event, p.Movie.Images)
+	if applicationEvent(&event, events.FirstNonEmpty(p.InstanceName, "Radarr"), p.EventType, p.ArrHealth, p.ArrUpdate) {
+		return event
+	}
+
+	movie := p.Movie
+	if movie.Title == "" {
+		movie = p.RemoteMovie
+	}
+	title := withYear(movie.Title, movie.Year)
+	switch p.EventType {
+	case "Grab":
+		event.Title = fmt.Sprintf("Grabbed: %s", title)
+		event.Body = p.Release.ReleaseTitle
+	case "Download", "Upgrade":
+		verb := "Downloaded"
+		if p.IsUpgrade || p.EventType == "Upgrade" {
+			verb = "Upgraded"
+		}
+		event.Title = fmt.Sprintf("%s: %s", verb, title)
+		event.Body = movie.Overview
+	case "ManualInteractionRequired":
+		event.Title = fmt.Sprintf("Manual import needed: %s", title)
+		event.Body = p.Release.ReleaseTitle
+	case "Rename":
+		event.Title = fmt.Sprintf("Renamed files: %s", title)
+		if count := len(p.RenamedMovieFiles); count > 0 {
+			event.Body = fmt.Sprintf("%d file(s) renamed", count)
+		}
+	case "MovieAdded":
+		event.Title = fmt.Sprintf("Movie added: %s", title)
+		event.Body = movie.Overview
+	case "MovieDelete":
+		event.Title = fmt.Sprintf("Movie removed: %s", title)
+	case "MovieFileDelete":
+		event.Title = fmt.Sprintf("File deleted: %s", title)
+	default:
+		event.Title = strings.TrimSpace(fmt.Sprintf("Radarr %s: %s", p.EventType, title))
+	}
+
+	event.Media = events.Media{
+		Type:   "movie",
+		Title:  movie.Title,
+		TmdbID: id(movie.TmdbID),
+		ImdbID: movie.ImdbID,
+	}
+
+	fileAttributes(&event, p.MovieFile)
+	releaseAttributes(&event, p.ArrDownload)
+	event.SetAttr("IMDb", movie.ImdbID)
+	return event
+}
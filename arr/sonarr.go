@@ -0,0 +1,147 @@
+package arr
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"jellynotifier/events"
+	"jellynotifier/models"
+)
+
+// SonarrAdapter converts Sonarr webhook payloads into events
+type SonarrAdapter struct{}
+
+// Source implements events.Adapter
+func (SonarrAdapter) Source() string {
+	return "sonarr"
+}
+
+// Detect recognises Sonarr by its User-Agent, or by an eventType next to a
+// series for proxies that replace the User-Agent
+func (SonarrAdapter) Detect(header http.Header, body []byte) bool {
+	return detect(header, body, "Sonarr/", "series")
+}
+
+// Decode implements events.Adapter
+func (SonarrAdapter) Decode(header http.Header, body []byte) ([]events.Event, error) {
+	event, err := DecodeSonarr(body)
+	if err != nil {
+		return nil, err
+	}
+	return []events.Event{event}, nil
+}
+
+// DecodeSonarr parses a Sonarr webhook payload into an event
+func DecodeSonarr(body []byte) (events.Event, error) {
+	var payload models.SonarrPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return events.Event{}, fmt.Errorf("error decoding Sonarr payload: %v", err)
+	}
+	if payload.EventType == "" {
+		return events.Event{}, fmt.Errorf("sonarr payload has no eventType")
+	}
+	log.Printf("[DEBUG] [ARR] Decoded Sonarr %s for %q", payload.EventType, payload.Series.Title)
+	return SonarrEvent(payload), nil
+}
+
+// SonarrEvent maps a Sonarr payload onto an event: a title per event type,
+// the series images, IDs and episodes, and release and file attributes
+func SonarrEvent(p models.SonarrPayload) events.Event {
+	event := events.Event{
+		Source: "sonarr",
+		Kind:   Kind(p.EventType, p.IsUpgrade),
+		Type:   p.EventType,
+	}
+	addImages(&event, p.Series.Images)
+	if applicationEvent(&event, events.FirstNonEmpty(p.InstanceName, "Sonarr"), p.EventType, p.ArrHealth, p.ArrUpdate) {
+		return event
+	}
+
+	series := withYear(p.Series.Title, p.Series.Year)
+	episodes := episodeTitle(p.Series.Title, p.Episodes)
+	switch p.EventType {
+	case "Grab":
+		event.Title = fmt.Sprintf("Grabbed: %s", episodes)
+		event.Body = p.Release.ReleaseTitle
+	case "Download", "Upgrade":
+		verb := "Downloaded"
+		if p.IsUpgrade || p.EventType == "Upgrade" {
+			verb = "Upgraded"
+		}
+		event.Title = fmt.Sprintf("%s: %s", verb, episodes)
+		if len(p.Episodes) == 1 {
+			event.Body = p.Episodes[0].Overview
+		}
+	case "ManualInteractionRequired":
+		event.Title = fmt.Sprintf("Manual import needed: %s", episodes)
+		event.Body = p.Release.ReleaseTitle
+	case "Rename":
+		event.Title = fmt.Sprintf("Renamed files: %s", series)
+		if count := len(p.RenamedEpisodeFiles); count > 0 {
+			event.Body = fmt.Sprintf("%d file(s) renamed", count)
+		}
+	case "SeriesAdd":
+		event.Title = fmt.Sprintf("Series added: %s", series)
+	case "SeriesDelete":
+		event.Title = fmt.Sprintf("Series removed: %s", series)
+	case "EpisodeFileDelete":
+		event.Title = fmt.Sprintf("File deleted: %s", episodes)
+	default:
+		event.Title = strings.TrimSpace(fmt.Sprintf("Sonarr %s: %s", p.EventType, series))
+	}
+
+	event.Media = events.Media{
+		Type:   "tv",
+		Title:  p.Series.Title,
+		TmdbID: id(p.Series.TmdbID),
+		TvdbID: id(p.Series.TvdbID),
+		ImdbID: p.Series.ImdbID,
+	}
+	if len(p.Episodes) > 0 {
+		event.Media.Season = p.Episodes[0].SeasonNumber
+		for _, episode := range p.Episodes {
+			event.Media.Episodes = append(event.Media.Episodes, episode.EpisodeNumber)
+		}
+	}
+
+	fileAttributes(&event, p.EpisodeFile)
+	releaseAttributes(&event, p.ArrDownload)
+	if len(p.Episodes) > 1 {
+		event.SetAttr("Episodes", fmt.Sprint(len(p.Episodes)))
+	}
+	event.SetAttr("IMDb", p.Series.ImdbID)
+	return event
+}
+
+// episodeTitle formats episodes as "Series S01E02 - Name", "Series S01E02-E04"
+// or, when they span seasons, "Series (5 episodes)"
+func episodeTitle(series string, episodes []models.SonarrEpisode) string {
+	switch len(episodes) {
+	case 0:
+		return series
+	case 1:
+		episode := episodes[0]
+		title := fmt.Sprintf("%s S%02dE%02d", series, episode.SeasonNumber, episode.EpisodeNumber)
+		if episode.Title != "" {
+			title += " - " + episode.Title
+		}
+		return title
+	}
+
+	first, last := episodes[0], episodes[0]
+	for _, episode := range episodes[1:] {
+		if episode.SeasonNumber != first.SeasonNumber {
+			return fmt.Sprintf("%s (%d episodes)", series, len(episodes))
+		}
+		if episode.EpisodeNumber < first.EpisodeNumber {
+			first = episode
+		}
+		if episode.EpisodeNumber > last.EpisodeNumber {
+			last = episode
+		}
+	}
+	return fmt.Sprintf("%s S%02dE%02d-E%02d", series, first.SeasonNumber, first.EpisodeNumber, last.EpisodeNumber)
+}
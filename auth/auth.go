@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBodySize caps how much of a webhook body is read for verification
+const MaxBodySize = 1 << 20
+
+// Config holds the webhook authentication settings
+type Config struct {
+	// Token is a static shared secret expected in TokenHeader
+	Token string
+	// TokenHeader is the header carrying Token. When it is "Authorization"
+	// both "Bearer <token>" and the raw token are accepted.
+	TokenHeader string
+	// HMACSecret enables HMAC-SHA256 body signatures when set
+	HMACSecret string
+	// SignatureHeader carries the hex signature, optionally prefixed with "sha256="
+	SignatureHeader string
+	// TimestampHeader carries the unix timestamp (seconds) covered by the signature
+	TimestampHeader string
+	// MaxSkew is how far the timestamp may drift from the local clock
+	MaxSkew time.Duration
+}
+
+// Verifier authenticates incoming webhook requests
+type Verifier struct {
+	cfg Config
+	now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// Errors returned by Verify
+var (
+	ErrMissingToken     = errors.New("missing authentication token")
+	ErrInvalidToken     = errors.New("invalid authentication token")
+	ErrMissingSignature = errors.New("missing signature or timestamp header")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleTimestamp   = errors.New("timestamp outside allowed window")
+	ErrReplayed         = errors.New("signature already used")
+)
+
+// NewVerifier creates a new webhook verifier, filling in default header names
+func NewVerifier(cfg Config) *Verifier {
+	log.Println("[DEBUG] [AUTH] Creating webhook verifier...")
+
+	if cfg.TokenHeader == "" {
+		cfg.TokenHeader = "Authorization"
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = "X-Jellynotifier-Signature"
+	}
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = "X-Jellynotifier-Timestamp"
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+
+	log.Printf("[DEBUG] [AUTH] Token auth enabled: %t (header: %s)", cfg.Token != "", cfg.TokenHeader)
+	log.Printf("[DEBUG] [AUTH] HMAC auth enabled: %t (signature header: %s, timestamp header: %s, max skew: %s)",
+		cfg.HMACSecret != "", cfg.SignatureHeader, cfg.TimestampHeader, cfg.MaxSkew)
+
+	return &Verifier{
+		cfg:  cfg,
+		now:  time.Now,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether any authentication method is configured
+func (v *Verifier) Enabled() bool {
+	return v != nil && (v.cfg.Token != "" || v.cfg.HMACSecret != "")
+}
+
+// Verify checks the request against every configured authentication method.
+// The body must be the exact bytes received, since the HMAC is computed over it.
+func (v *Verifier) Verify(r *http.Request, body []byte) error {
+	if v.cfg.Token != "" {
+		if err := v.verifyToken(r); err != nil {
+			return err
+		}
+	}
+	if v.cfg.HMACSecret != "" {
+		if err := v.verifySignature(r, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyToken compares the configured token header in constant time
+func (v *Verifier) verifyToken(r *http.Request) error {
+	value := strings.TrimSpace(r.Header.Get(v.cfg.TokenHeader))
+	if value == "" {
+		return ErrMissingToken
+	}
+	if strings.EqualFold(v.cfg.TokenHeader, "Authorization") && len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+		value = strings.TrimSpace(value[7:])
+	}
+	if subtle.ConstantTimeCompare([]byte(value), []byte(v.cfg.Token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// verifySignature checks HMAC-SHA256(secret, timestamp + "." + body) and rejects
+// stale timestamps and signatures that were already accepted inside the window
+func (v *Verifier) verifySignature(r *http.Request, body []byte) error {
+	signature := strings.TrimSpace(r.Header.Get(v.cfg.SignatureHeader))
+	timestamp := strings.TrimSpace(r.Header.Get(v.cfg.TimestampHeader))
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	signature = strings.TrimPrefix(signature, "sha256=")
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStaleTimestamp, err)
+	}
+	now := v.now()
+	skew := now.Sub(time.Unix(unix, 0))
+	if math.Abs(float64(skew)) > float64(v.cfg.MaxSkew) {
+		return ErrStaleTimestamp
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(got, Sign(v.cfg.HMACSecret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, expires := range v.seen {
+		if now.After(expires) {
+			delete(v.seen, key)
+		}
+	}
+	if _, ok := v.seen[signature]; ok {
+		return ErrReplayed
+	}
+	v.seen[signature] = now.Add(2 * v.cfg.MaxSkew)
+	return nil
+}
+
+// Sign computes the raw HMAC-SHA256 signature for a timestamp and body
+func Sign(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Middleware wraps a handler so that only authenticated requests reach it.
+// The body is buffered for verification and restored for the next handler.
+func (v *Verifier) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	if !v.Enabled() {
+		log.Println("[WARN] [AUTH] No webhook authentication configured - accepting unauthenticated requests")
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			log.Printf("[ERROR] [AUTH] Failed to read request body from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if len(body) > MaxBodySize {
+			log.Printf("[WARN] [AUTH] Request body from %s exceeds %d bytes", r.RemoteAddr, MaxBodySize)
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if err := v.Verify(r, body); err != nil {
+			log.Printf("[WARN] [AUTH] Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("[DEBUG] [AUTH] Authenticated %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
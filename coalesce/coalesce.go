@@ -0,0 +1,201 @@
+package coalesce
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"jellynotifier/activity"
+	"jellynotifier/events"
+)
+
+// Options controls which events are batched and for how long
+type Options struct {
+	// Window is how long a batch waits for another episode; 0 disables batching
+	Window time.Duration
+	// MaxWait caps how long a batch is held after its first episode
+	MaxWait time.Duration
+	// MaxItems flushes a batch as soon as it holds this many episodes
+	MaxItems int
+	// Events lists the event kinds (with * wildcards) that are batched
+	Events []string
+}
+
+// Enabled reports whether batching is turned on
+func (o Options) Enabled() bool {
+	return o.Window > 0
+}
+
+// Item is a held event and its outbox entry
+type Item struct {
+	ID         string
+	Event      events.Event
+	AcceptedAt time.Time
+}
+
+// FlushFunc receives the items of a batch in arrival order. ctx is
+// cancelled when Close gives up waiting for the flush.
+type FlushFunc func(ctx context.Context, items []Item)
+
+// Batcher holds episode events of the same series and kind until no new
+// episode arrived for Window, MaxWait passed or MaxItems were collected,
+// then hands the batch to its FlushFunc.
+type Batcher struct {
+	opts  Options
+	flush FlushFunc
+
+	mu      sync.Mutex
+	batches map[string]*batch
+	closed  bool
+	// flushing tracks FlushFunc calls so Close can wait for them
+	flushing sync.WaitGroup
+	// ctx is passed to every FlushFunc call and cancelled when the
+	// context given to Close expires
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// batch is the pending items of one key
+type batch struct {
+	items    []Item
+	deadline time.Time // first arrival + MaxWait
+	due      time.Time // when the batch is flushed unless another item arrives
+	timer    *time.Timer
+}
+
+// New creates a batcher that passes every finished batch to flush
+func New(opts Options, flush FlushFunc) *Batcher {
+	if opts.MaxWait < opts.Window {
+		opts.MaxWait = opts.Window
+	}
+	log.Printf("[DEBUG] [COALESCE] Batching %v episode events - Window: %s, MaxWait: %s, MaxItems: %d", opts.Events, opts.Window, opts.MaxWait, opts.MaxItems)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Batcher{
+		opts:    opts,
+		flush:   flush,
+		batches: make(map[string]*batch),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Matches reports whether the event is an episode event of a batched kind
+func (b *Batcher) Matches(event events.Event) bool {
+	if event.Media.Type != "tv" || len(event.Media.Episodes) == 0 || event.Media.Title == "" {
+		return false
+	}
+	for _, pattern := range b.opts.Events {
+		if activity.Matches(pattern, event.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key groups events by source, kind and series. The series title is used
+// rather than the TVDB ID since Jellyfin reports the episode's ID.
+func Key(event events.Event) string {
+	return strings.Join([]string{event.Source, event.Kind, strings.ToLower(event.Media.Title)}, "|")
+}
+
+// Add holds the item if its event is batched and reports whether it did.
+// Items that are not held must be delivered by the caller.
+func (b *Batcher) Add(item Item) bool {
+	if !b.Matches(item.Event) {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+
+	key := Key(item.Event)
+	now := time.Now()
+	pending, ok := b.batches[key]
+	if !ok {
+		pending = &batch{deadline: now.Add(b.opts.MaxWait)}
+		b.batches[key] = pending
+		log.Printf("[DEBUG] [COALESCE] Started batch %s", key)
+	}
+	pending.items = append(pending.items, item)
+
+	if b.opts.MaxItems > 0 && len(pending.items) >= b.opts.MaxItems {
+		log.Printf("[DEBUG] [COALESCE] Batch %s reached %d items, flushing", key, len(pending.items))
+		b.take(key, pending)
+		b.flushing.Add(1)
+		go b.run(pending.items)
+		return true
+	}
+
+	// due only moves forward, so an early timer just re-arms itself
+	pending.due = now.Add(b.opts.Window)
+	if pending.due.After(pending.deadline) {
+		pending.due = pending.deadline
+	}
+	if pending.timer == nil {
+		pending.timer = time.AfterFunc(b.opts.Window, func() { b.expire(key, pending) })
+	}
+	return true
+}
+
+// Close flushes every pending batch and waits for flushes in progress.
+// Once ctx expires the flushes are cancelled. Later calls to Add hold nothing.
+func (b *Batcher) Close(ctx context.Context) {
+	stop := context.AfterFunc(ctx, b.cancel)
+	defer stop()
+
+	b.mu.Lock()
+	b.closed = true
+	var pending [][]Item
+	for key, batch := range b.batches {
+		b.take(key, batch)
+		pending = append(pending, batch.items)
+	}
+	b.mu.Unlock()
+
+	log.Printf("[DEBUG] [COALESCE] Flushing %d pending batch(es)", len(pending))
+	for _, items := range pending {
+		b.flushing.Add(1)
+		b.run(items)
+	}
+	b.flushing.Wait()
+	b.cancel()
+}
+
+// expire flushes a batch once it is due
+func (b *Batcher) expire(key string, pending *batch) {
+	b.mu.Lock()
+	if b.batches[key] != pending {
+		b.mu.Unlock()
+		return
+	}
+	if wait := time.Until(pending.due); wait > 0 {
+		pending.timer.Reset(wait)
+		b.mu.Unlock()
+		return
+	}
+	b.take(key, pending)
+	b.flushing.Add(1)
+	b.mu.Unlock()
+
+	log.Printf("[DEBUG] [COALESCE] Batch %s is due with %d item(s)", key, len(pending.items))
+	b.run(pending.items)
+}
+
+// take removes a batch and stops its timer; b.mu must be held
+func (b *Batcher) take(key string, pending *batch) {
+	delete(b.batches, key)
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+}
+
+// run calls the FlushFunc for one batch
+func (b *Batcher) run(items []Item) {
+	defer b.flushing.Done()
+	b.flush(b.ctx, items)
+}
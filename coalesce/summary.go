@@ -0,0 +1,147 @@
+package coalesce
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jellynotifier/events"
+)
+
+// maxBodyLines bounds how many episode titles a summary lists
+const maxBodyLines = 10
+
+// verbs describe what happened to the episodes of a summary
+var verbs = map[string]string{
+	"download.grabbed":   "grabbed",
+	"download.completed": "downloaded",
+	"download.upgraded":  "upgraded",
+	"item.added":         "available",
+	"media.available":    "available",
+}
+
+// Summary merges the events of a batch into one, titled like
+// "Show S02: episodes 1–10 available". It keeps the first event's source,
+// kind, images and IDs, lists the episode titles in the body and keeps
+// the attributes every event agrees on.
+func Summary(batch []events.Event) events.Event {
+	if len(batch) == 0 {
+		return events.Event{}
+	}
+	first := batch[0]
+	if len(batch) == 1 {
+		return first
+	}
+
+	summary := events.Event{
+		Source: first.Source,
+		Kind:   first.Kind,
+		Type:   first.Type,
+		Images: first.Images,
+		Media: events.Media{
+			Type:   first.Media.Type,
+			Title:  first.Media.Title,
+			TmdbID: first.Media.TmdbID,
+			TvdbID: first.Media.TvdbID,
+			ImdbID: first.Media.ImdbID,
+		},
+	}
+
+	// Episodes per season, without repeats
+	seasons := make(map[int]map[int]bool)
+	for _, event := range batch {
+		if seasons[event.Media.Season] == nil {
+			seasons[event.Media.Season] = make(map[int]bool)
+		}
+		for _, episode := range event.Media.Episodes {
+			seasons[event.Media.Season][episode] = true
+		}
+		for _, actor := range event.Actors {
+			if summary.Actor(actor.Role).Name == "" {
+				summary.AddActor(actor)
+			}
+		}
+	}
+	numbers := make([]int, 0, len(seasons))
+	count := 0
+	for season, episodes := range seasons {
+		numbers = append(numbers, season)
+		count += len(episodes)
+	}
+	sort.Ints(numbers)
+
+	verb := verbs[first.Kind]
+	if verb == "" {
+		verb = first.Kind
+	}
+	if len(numbers) == 1 {
+		season := numbers[0]
+		episodes := sorted(seasons[season])
+		summary.Media.Season = season
+		summary.Media.Episodes = episodes
+		noun := "episodes"
+		if len(episodes) == 1 {
+			noun = "episode"
+		}
+		summary.Title = fmt.Sprintf("%s S%02d: %s %s %s", first.Media.Title, season, noun, Ranges(episodes), verb)
+	} else {
+		summary.Title = fmt.Sprintf("%s S%02d–S%02d: %d episodes %s", first.Media.Title, numbers[0], numbers[len(numbers)-1], count, verb)
+	}
+
+	lines := make([]string, 0, maxBodyLines+1)
+	for i, event := range batch {
+		if i == maxBodyLines {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(batch)-maxBodyLines))
+			break
+		}
+		lines = append(lines, event.Title)
+	}
+	summary.Body = strings.Join(lines, "\n")
+
+	summary.SetAttr("Episodes", strconv.Itoa(count))
+	for _, attr := range first.Attributes {
+		if attr.Name != "Episodes" && shared(batch, attr) {
+			summary.SetAttr(attr.Name, attr.Value)
+		}
+	}
+	return summary
+}
+
+// shared reports whether every event has the attribute with the same value
+func shared(batch []events.Event, attr events.Attribute) bool {
+	for _, event := range batch[1:] {
+		if event.Attr(attr.Name) != attr.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// sorted returns the keys of set in ascending order
+func sorted(set map[int]bool) []int {
+	numbers := make([]int, 0, len(set))
+	for number := range set {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// Ranges formats ascending numbers as "1–3, 5, 7–9"
+func Ranges(numbers []int) string {
+	var parts []string
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d–%d", numbers[i], numbers[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(numbers[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"jellynotifier/retry"
+	"jellynotifier/routing"
 )
 
 // Config holds all configuration values for the application
@@ -13,42 +19,370 @@ type Config struct {
 	DiscordToken   string
 	DiscordChannel string
 	EnableDiscord  bool
+
+	// Discord direct messages to requesters
+	DiscordDMEnabled bool
+	DiscordDMEvents  []string
+	DiscordDMOptOut  []string
+
+	// Events whose channel posts @mention the user they concern
+	DiscordMentionEvents []string
+
+	// Discord channel routing table
+	DiscordRoutes routing.Table
+
+	// Edit a request's message as its status changes
+	DiscordEditMessages     bool
+	DiscordNewMessageEvents []string
+
+	// Start a thread per issue for its comments and status changes
+	DiscordIssueThreads bool
+
+	// Slash commands and the roles allowed to use them
+	DiscordCommandsEnabled bool
+	DiscordCommandsGuildID string
+	DiscordCommandRoles    []string
+
+	// Roles allowed to approve or decline requests from Discord
+	DiscordAdminRoles []string
+
+	// Overseerr/Jellyseerr API used by the approve and decline buttons
+	OverseerrURL    string
+	OverseerrAPIKey string
+
+	// Directory of per-event message templates; empty uses the built-in layouts
+	TemplatesDir string
+
+	// Slack incoming webhook sink
+	EnableSlack     bool
+	SlackWebhookURL string
+
+	// Telegram Bot API sink
+	EnableTelegram  bool
+	TelegramToken   string
+	TelegramChatID  string
+	TelegramAPIURL  string
+	TelegramDMUsers bool
+
+	// Webhook authentication
+	WebhookToken           string
+	WebhookTokenHeader     string
+	WebhookHMACSecret      string
+	WebhookSignatureHeader string
+	WebhookTimestampHeader string
+	WebhookMaxSkew         time.Duration
+
+	// Persistent state (outbox, dead letters)
+	DataDir string
+
+	// Admin API bearer token; admin endpoints are disabled when empty
+	AdminToken string
+
+	// Delivery queue
+	QueueSize       int
+	QueueWorkers    int
+	ShutdownTimeout time.Duration
+
+	// Duplicate webhook suppression; a zero window disables it
+	DedupWindow  time.Duration
+	DedupSize    int
+	DedupPersist bool
+
+	// Episode batching into one summary per series; a zero window disables it
+	CoalesceWindow   time.Duration
+	CoalesceMaxWait  time.Duration
+	CoalesceMaxItems int
+	CoalesceEvents   []string
+
+	// Scheduled digests of collected notifications
+	Digests []Digest
+
+	// How often the config file is checked for changes; 0 disables watching
+	WatchInterval time.Duration
+
+	// Delivery retry policy
+	RetryMaxAttempts     int
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+	RetryMaxElapsed      time.Duration
+}
+
+// Load reads configuration from the optional config file at path, then
+// applies environment variable overrides and validates the result. Errors
+// for keys set in the file name the file, line and key path.
+func Load(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// LoadLenient is Load without the checks for sink credentials, for commands
+// such as render that never connect to a sink
+func LoadLenient(path string) (*Config, error) {
+	return load(path, false)
 }
 
-// Load reads configuration from environment variables with validation
-func Load() (*Config, error) {
+// load reads and validates the configuration; strict requires the
+// credentials of every enabled sink
+func load(path string, strict bool) (*Config, error) {
 	log.Println("[DEBUG] [CONFIG] Starting configuration loading...")
 
-	cfg := &Config{
-		Port:           getEnv("PORT", "8080"),
-		DiscordToken:   getEnv("DISCORD_TOKEN", ""),
-		DiscordChannel: getEnv("DISCORD_CHANNEL_ID", ""),
-		EnableDiscord:  getBoolEnv("ENABLE_DISCORD", true),
+	cfg := defaults()
+
+	var src *source
+	if path != "" {
+		var err error
+		if src, err = loadFile(path, cfg); err != nil {
+			log.Printf("[ERROR] [CONFIG] Invalid config file: %v", err)
+			return nil, err
+		}
+	} else {
+		log.Printf("[DEBUG] [CONFIG] No config file given (--config or %s), using environment only", PathEnv)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
 	}
 
 	log.Printf("[DEBUG] [CONFIG] PORT: %s", cfg.Port)
 	log.Printf("[DEBUG] [CONFIG] ENABLE_DISCORD: %t", cfg.EnableDiscord)
 	log.Printf("[DEBUG] [CONFIG] DISCORD_TOKEN present: %t", cfg.DiscordToken != "")
 	log.Printf("[DEBUG] [CONFIG] DISCORD_CHANNEL_ID present: %t", cfg.DiscordChannel != "")
+	log.Printf("[DEBUG] [CONFIG] DISCORD_DM_ENABLED: %t, DISCORD_DM_EVENTS: %v, DISCORD_DM_OPT_OUT: %d users", cfg.DiscordDMEnabled, cfg.DiscordDMEvents, len(cfg.DiscordDMOptOut))
+	log.Printf("[DEBUG] [CONFIG] DISCORD_MENTION_EVENTS: %v", cfg.DiscordMentionEvents)
+	log.Printf("[DEBUG] [CONFIG] DISCORD_EDIT_MESSAGES: %t, DISCORD_NEW_MESSAGE_EVENTS: %v", cfg.DiscordEditMessages, cfg.DiscordNewMessageEvents)
+	log.Printf("[DEBUG] [CONFIG] DISCORD_ISSUE_THREADS: %t", cfg.DiscordIssueThreads)
+	log.Printf("[DEBUG] [CONFIG] DISCORD_COMMANDS_ENABLED: %t, DISCORD_COMMANDS_GUILD_ID: %q, DISCORD_COMMAND_ROLES: %d roles", cfg.DiscordCommandsEnabled, cfg.DiscordCommandsGuildID, len(cfg.DiscordCommandRoles))
+	log.Printf("[DEBUG] [CONFIG] DISCORD_ADMIN_ROLES: %d roles, OVERSEERR_URL: %q, OVERSEERR_API_KEY present: %t", len(cfg.DiscordAdminRoles), cfg.OverseerrURL, cfg.OverseerrAPIKey != "")
+	log.Printf("[DEBUG] [CONFIG] DISCORD_ROUTES: %d rules, mode: %s, default: %v", len(cfg.DiscordRoutes.Rules), cfg.DiscordRoutes.Mode, cfg.DiscordRoutes.Default)
+	log.Printf("[DEBUG] [CONFIG] TEMPLATES_DIR: %s", cfg.TemplatesDir)
+	log.Printf("[DEBUG] [CONFIG] ENABLE_SLACK: %t, SLACK_WEBHOOK_URL present: %t", cfg.EnableSlack, cfg.SlackWebhookURL != "")
+	log.Printf("[DEBUG] [CONFIG] ENABLE_TELEGRAM: %t, TELEGRAM_BOT_TOKEN present: %t, TELEGRAM_CHAT_ID present: %t", cfg.EnableTelegram, cfg.TelegramToken != "", cfg.TelegramChatID != "")
+	log.Printf("[DEBUG] [CONFIG] WEBHOOK_TOKEN present: %t", cfg.WebhookToken != "")
+	log.Printf("[DEBUG] [CONFIG] WEBHOOK_HMAC_SECRET present: %t", cfg.WebhookHMACSecret != "")
+	log.Printf("[DEBUG] [CONFIG] DATA_DIR: %s", cfg.DataDir)
+	log.Printf("[DEBUG] [CONFIG] ADMIN_TOKEN present: %t", cfg.AdminToken != "")
+	log.Printf("[DEBUG] [CONFIG] QUEUE_SIZE: %d, QUEUE_WORKERS: %d", cfg.QueueSize, cfg.QueueWorkers)
+	log.Printf("[DEBUG] [CONFIG] DEDUP_WINDOW: %s, DEDUP_SIZE: %d, DEDUP_PERSIST: %t", cfg.DedupWindow, cfg.DedupSize, cfg.DedupPersist)
+	log.Printf("[DEBUG] [CONFIG] COALESCE_WINDOW: %s, COALESCE_MAX_WAIT: %s, COALESCE_MAX_ITEMS: %d, COALESCE_EVENTS: %v", cfg.CoalesceWindow, cfg.CoalesceMaxWait, cfg.CoalesceMaxItems, cfg.CoalesceEvents)
+	log.Printf("[DEBUG] [CONFIG] DIGESTS: %d digest(s)", len(cfg.Digests))
+	log.Printf("[DEBUG] [CONFIG] RETRY_MAX_ATTEMPTS: %d, RETRY_MAX_ELAPSED: %s", cfg.RetryMaxAttempts, cfg.RetryMaxElapsed)
+
+	if err := cfg.validate(src, strict); err != nil {
+		log.Printf("[ERROR] [CONFIG] %v", err)
+		return nil, err
+	}
+
+	log.Println("[DEBUG] [CONFIG] Configuration loading completed successfully")
+	return cfg, nil
+}
+
+// defaults returns the configuration used for keys set neither in the
+// config file nor in the environment
+func defaults() *Config {
+	return &Config{
+		Port:          "8080",
+		EnableDiscord: true,
+
+		DiscordDMEvents: []string{"media.available", "media.approved", "media.declined"},
+
+		TelegramAPIURL: "https://api.telegram.org",
+
+		WebhookTokenHeader:     "Authorization",
+		WebhookSignatureHeader: "X-Jellynotifier-Signature",
+		WebhookTimestampHeader: "X-Jellynotifier-Timestamp",
+		WebhookMaxSkew:         5 * time.Minute,
+
+		DataDir: "data",
+
+		QueueSize:       100,
+		QueueWorkers:    2,
+		ShutdownTimeout: 25 * time.Second,
+
+		DedupSize: 1000,
+
+		CoalesceMaxWait:  5 * time.Minute,
+		CoalesceMaxItems: 50,
+		CoalesceEvents:   []string{"download.grabbed", "download.completed", "download.upgraded", "item.added"},
+
+		WatchInterval: 10 * time.Second,
+
+		RetryMaxAttempts:     5,
+		RetryInitialInterval: time.Second,
+		RetryMaxInterval:     30 * time.Second,
+		RetryMaxElapsed:      2 * time.Minute,
+	}
+}
+
+// applyEnv overrides individual keys with any environment variables that are set
+func (c *Config) applyEnv() error {
+	c.Port = getEnv("PORT", c.Port)
+	c.DiscordToken = getEnv("DISCORD_TOKEN", c.DiscordToken)
+	c.DiscordChannel = getEnv("DISCORD_CHANNEL_ID", c.DiscordChannel)
+	c.EnableDiscord = getBoolEnv("ENABLE_DISCORD", c.EnableDiscord)
+
+	c.DiscordDMEnabled = getBoolEnv("DISCORD_DM_ENABLED", c.DiscordDMEnabled)
+	c.DiscordDMEvents = getListEnv("DISCORD_DM_EVENTS", c.DiscordDMEvents)
+	c.DiscordDMOptOut = getListEnv("DISCORD_DM_OPT_OUT", c.DiscordDMOptOut)
+
+	c.DiscordMentionEvents = getListEnv("DISCORD_MENTION_EVENTS", c.DiscordMentionEvents)
+
+	c.DiscordEditMessages = getBoolEnv("DISCORD_EDIT_MESSAGES", c.DiscordEditMessages)
+	c.DiscordNewMessageEvents = getListEnv("DISCORD_NEW_MESSAGE_EVENTS", c.DiscordNewMessageEvents)
+	c.DiscordIssueThreads = getBoolEnv("DISCORD_ISSUE_THREADS", c.DiscordIssueThreads)
+
+	c.DiscordCommandsEnabled = getBoolEnv("DISCORD_COMMANDS_ENABLED", c.DiscordCommandsEnabled)
+	c.DiscordCommandsGuildID = getEnv("DISCORD_COMMANDS_GUILD_ID", c.DiscordCommandsGuildID)
+	c.DiscordCommandRoles = getListEnv("DISCORD_COMMAND_ROLES", c.DiscordCommandRoles)
+	c.DiscordAdminRoles = getListEnv("DISCORD_ADMIN_ROLES", c.DiscordAdminRoles)
+
+	c.OverseerrURL = getEnv("OVERSEERR_URL", c.OverseerrURL)
+	c.OverseerrAPIKey = getEnv("OVERSEERR_API_KEY", c.OverseerrAPIKey)
+
+	// Routing rules are JSON: {"mode": "first-match", "rules": [...], "default": [...]}
+	if value := os.Getenv("DISCORD_ROUTES"); value != "" {
+		routes, err := routing.Parse(value)
+		if err != nil {
+			log.Printf("[ERROR] [CONFIG] Invalid DISCORD_ROUTES: %v", err)
+			return fmt.Errorf("invalid DISCORD_ROUTES: %v", err)
+		}
+		c.DiscordRoutes = routes
+	}
+
+	// Digests are JSON: [{"name": "daily", "schedule": "0 9 * * *", "match": {...}}]
+	if value := os.Getenv("DIGESTS"); value != "" {
+		digests, err := parseDigests(value)
+		if err != nil {
+			log.Printf("[ERROR] [CONFIG] Invalid DIGESTS: %v", err)
+			return fmt.Errorf("invalid DIGESTS: %v", err)
+		}
+		c.Digests = digests
+	}
+
+	c.TemplatesDir = getEnv("TEMPLATES_DIR", c.TemplatesDir)
+
+	c.EnableSlack = getBoolEnv("ENABLE_SLACK", c.EnableSlack)
+	c.SlackWebhookURL = getEnv("SLACK_WEBHOOK_URL", c.SlackWebhookURL)
+
+	c.EnableTelegram = getBoolEnv("ENABLE_TELEGRAM", c.EnableTelegram)
+	c.TelegramToken = getEnv("TELEGRAM_BOT_TOKEN", c.TelegramToken)
+	c.TelegramChatID = getEnv("TELEGRAM_CHAT_ID", c.TelegramChatID)
+	c.TelegramAPIURL = getEnv("TELEGRAM_API_URL", c.TelegramAPIURL)
+	c.TelegramDMUsers = getBoolEnv("TELEGRAM_DM_USERS", c.TelegramDMUsers)
+
+	c.WebhookToken = getEnv("WEBHOOK_TOKEN", c.WebhookToken)
+	c.WebhookTokenHeader = getEnv("WEBHOOK_TOKEN_HEADER", c.WebhookTokenHeader)
+	c.WebhookHMACSecret = getEnv("WEBHOOK_HMAC_SECRET", c.WebhookHMACSecret)
+	c.WebhookSignatureHeader = getEnv("WEBHOOK_SIGNATURE_HEADER", c.WebhookSignatureHeader)
+	c.WebhookTimestampHeader = getEnv("WEBHOOK_TIMESTAMP_HEADER", c.WebhookTimestampHeader)
+	c.WebhookMaxSkew = getDurationEnv("WEBHOOK_MAX_SKEW", c.WebhookMaxSkew)
 
+	c.DataDir = getEnv("DATA_DIR", c.DataDir)
+	c.AdminToken = getEnv("ADMIN_TOKEN", c.AdminToken)
+
+	c.QueueSize = getIntEnv("QUEUE_SIZE", c.QueueSize)
+	c.QueueWorkers = getIntEnv("QUEUE_WORKERS", c.QueueWorkers)
+	c.ShutdownTimeout = getDurationEnv("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	c.WatchInterval = getDurationEnv("CONFIG_WATCH_INTERVAL", c.WatchInterval)
+
+	c.DedupWindow = getDurationEnv("DEDUP_WINDOW", c.DedupWindow)
+	c.DedupSize = getIntEnv("DEDUP_SIZE", c.DedupSize)
+	c.DedupPersist = getBoolEnv("DEDUP_PERSIST", c.DedupPersist)
+
+	c.CoalesceWindow = getDurationEnv("COALESCE_WINDOW", c.CoalesceWindow)
+	c.CoalesceMaxWait = getDurationEnv("COALESCE_MAX_WAIT", c.CoalesceMaxWait)
+	c.CoalesceMaxItems = getIntEnv("COALESCE_MAX_ITEMS", c.CoalesceMaxItems)
+	c.CoalesceEvents = getListEnv("COALESCE_EVENTS", c.CoalesceEvents)
+
+	c.RetryMaxAttempts = getIntEnv("RETRY_MAX_ATTEMPTS", c.RetryMaxAttempts)
+	c.RetryInitialInterval = getDurationEnv("RETRY_INITIAL_INTERVAL", c.RetryInitialInterval)
+	c.RetryMaxInterval = getDurationEnv("RETRY_MAX_INTERVAL", c.RetryMaxInterval)
+	c.RetryMaxElapsed = getDurationEnv("RETRY_MAX_ELAPSED", c.RetryMaxElapsed)
+	return nil
+}
+
+// validate checks the merged configuration. src may be nil when no config
+// file was loaded; otherwise errors carry the file and line of the key.
+func (c *Config) validate(src *source, strict bool) error {
 	// Validate required Discord configuration if Discord is enabled
-	if cfg.EnableDiscord {
+	if c.EnableDiscord && strict {
 		log.Println("[DEBUG] [CONFIG] Discord is enabled, validating required configuration...")
-		if cfg.DiscordToken == "" {
-			log.Println("[ERROR] [CONFIG] DISCORD_TOKEN is required when Discord is enabled")
-			return nil, fmt.Errorf("DISCORD_TOKEN environment variable is required when Discord is enabled")
+		if c.DiscordToken == "" {
+			return src.errorf("discord.token", "required when Discord is enabled (or set DISCORD_TOKEN)")
 		}
-		if cfg.DiscordChannel == "" {
-			log.Println("[ERROR] [CONFIG] DISCORD_CHANNEL_ID is required when Discord is enabled")
-			return nil, fmt.Errorf("DISCORD_CHANNEL_ID environment variable is required when Discord is enabled")
+		if c.DiscordChannel == "" {
+			return src.errorf("discord.channel_id", "required when Discord is enabled (or set DISCORD_CHANNEL_ID)")
 		}
 		log.Println("[DEBUG] [CONFIG] Discord configuration validation passed")
 	} else {
 		log.Println("[DEBUG] [CONFIG] Discord is disabled, skipping Discord configuration validation")
 	}
 
-	log.Println("[DEBUG] [CONFIG] Configuration loading completed successfully")
-	return cfg, nil
+	if err := c.DiscordRoutes.Validate(); err != nil {
+		return src.errorf("discord.routes", "%v", err)
+	}
+
+	if c.EnableSlack && strict && c.SlackWebhookURL == "" {
+		return src.errorf("slack.webhook_url", "required when Slack is enabled (or set SLACK_WEBHOOK_URL)")
+	}
+
+	if c.EnableTelegram && strict {
+		if c.TelegramToken == "" {
+			return src.errorf("telegram.token", "required when Telegram is enabled (or set TELEGRAM_BOT_TOKEN)")
+		}
+		if c.TelegramChatID == "" {
+			return src.errorf("telegram.chat_id", "required when Telegram is enabled (or set TELEGRAM_CHAT_ID)")
+		}
+	}
+
+	if c.OverseerrURL != "" {
+		if u, err := url.Parse(c.OverseerrURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return src.errorf("overseerr.url", "must be an absolute URL, got %q", c.OverseerrURL)
+		}
+		if strict && c.OverseerrAPIKey == "" {
+			return src.errorf("overseerr.api_key", "required when the Overseerr URL is set (or set OVERSEERR_API_KEY)")
+		}
+	}
+
+	if c.DataDir == "" {
+		return src.errorf("data_dir", "must not be empty (DATA_DIR)")
+	}
+	if c.QueueSize < 1 {
+		return src.errorf("queue.size", "must be at least 1, got %d", c.QueueSize)
+	}
+	if c.QueueWorkers < 1 {
+		return src.errorf("queue.workers", "must be at least 1, got %d", c.QueueWorkers)
+	}
+
+	if c.DedupWindow < 0 {
+		return src.errorf("dedup.window", "must not be negative, got %s", c.DedupWindow)
+	}
+	if c.DedupWindow > 0 && c.DedupSize < 1 {
+		return src.errorf("dedup.size", "must be at least 1, got %d", c.DedupSize)
+	}
+
+	if c.CoalesceWindow < 0 {
+		return src.errorf("coalesce.window", "must not be negative, got %s", c.CoalesceWindow)
+	}
+	if c.CoalesceWindow > 0 {
+		if c.CoalesceMaxWait < c.CoalesceWindow {
+			return src.errorf("coalesce.max_wait", "must be at least the window (%s), got %s", c.CoalesceWindow, c.CoalesceMaxWait)
+		}
+		if c.CoalesceMaxItems < 2 {
+			return src.errorf("coalesce.max_items", "must be at least 2, got %d", c.CoalesceMaxItems)
+		}
+	}
+
+	if err := validateDigests(c.Digests); err != nil {
+		return src.errorf("digests", "%v", err)
+	}
+
+	if c.RetryMaxAttempts < 1 {
+		return src.errorf("retry.max_attempts", "must be at least 1, got %d", c.RetryMaxAttempts)
+	}
+
+	if c.WebhookToken == "" && c.WebhookHMACSecret == "" {
+		log.Println("[WARN] [CONFIG] Neither WEBHOOK_TOKEN nor WEBHOOK_HMAC_SECRET is set - /webhook is unauthenticated")
+	}
+	return nil
 }
 
 // getEnv gets an environment variable with a fallback default value
@@ -75,3 +409,59 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getIntEnv gets an integer environment variable with a fallback default value
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		log.Printf("[DEBUG] [CONFIG] Environment variable %s found with value: %s", key, value)
+		if parsed, err := strconv.Atoi(value); err == nil {
+			log.Printf("[DEBUG] [CONFIG] Successfully parsed %s as integer: %d", key, parsed)
+			return parsed
+		}
+		log.Printf("[DEBUG] [CONFIG] Failed to parse %s as integer, using default: %d", key, defaultValue)
+	} else {
+		log.Printf("[DEBUG] [CONFIG] Environment variable %s not found, using default: %d", key, defaultValue)
+	}
+	return defaultValue
+}
+
+// getListEnv gets a comma-separated environment variable with a fallback default value
+func getListEnv(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		log.Printf("[DEBUG] [CONFIG] Environment variable %s found with value: %s", key, value)
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	log.Printf("[DEBUG] [CONFIG] Environment variable %s not found, using default: %v", key, defaultValue)
+	return defaultValue
+}
+
+// getDurationEnv gets a duration environment variable with a fallback default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		log.Printf("[DEBUG] [CONFIG] Environment variable %s found with value: %s", key, value)
+		if parsed, err := time.ParseDuration(value); err == nil {
+			log.Printf("[DEBUG] [CONFIG] Successfully parsed %s as duration: %s", key, parsed)
+			return parsed
+		}
+		log.Printf("[DEBUG] [CONFIG] Failed to parse %s as duration, using default: %s", key, defaultValue)
+	} else {
+		log.Printf("[DEBUG] [CONFIG] Environment variable %s not found, using default: %s", key, defaultValue)
+	}
+	return defaultValue
+}
+
+// RetryPolicy builds the delivery retry policy from the configuration
+func (c *Config) RetryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = c.RetryMaxAttempts
+	policy.InitialInterval = c.RetryInitialInterval
+	policy.MaxInterval = c.RetryMaxInterval
+	policy.MaxElapsed = c.RetryMaxElapsed
+	return policy
+}
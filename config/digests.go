@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"jellynotifier/routing"
+)
+
+// Digest describes one scheduled digest: which notifications it collects,
+// when it is posted and through which sink. The schedule is parsed when
+// the digest is opened.
+type Digest struct {
+	// Name identifies the digest; its sink is "digest-<name>" and its
+	// pages have the kind "digest.<name>" for routing
+	Name string `json:"name" yaml:"name"`
+	// Schedule is a cron expression such as "0 9 * * *" or "@weekly"
+	Schedule string `json:"schedule" yaml:"schedule"`
+	// Sink posts the digest, "discord" when empty
+	Sink string `json:"sink,omitempty" yaml:"sink,omitempty"`
+	// Title heads every page, "What's new" when empty
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+	// Match selects the notifications collected; empty matches everything
+	Match routing.Match `json:"match,omitempty" yaml:"match,omitempty"`
+}
+
+// digestName restricts digest names to what is safe in file and sink names
+var digestName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// parseDigests decodes the JSON list of digests given in DIGESTS
+func parseDigests(data string) ([]Digest, error) {
+	var digests []Digest
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&digests); err != nil {
+		return nil, fmt.Errorf("error parsing digests: %v", err)
+	}
+	return digests, nil
+}
+
+// validateDigests checks the names are usable and unique, and that every
+// digest has a schedule and a valid matcher
+func validateDigests(digests []Digest) error {
+	names := make(map[string]bool)
+	for i, d := range digests {
+		if !digestName.MatchString(d.Name) {
+			return fmt.Errorf("digest %d: name must be lowercase letters, digits, - or _, got %q", i, d.Name)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("digest %d: duplicate name %q", i, d.Name)
+		}
+		names[d.Name] = true
+		if strings.TrimSpace(d.Schedule) == "" {
+			return fmt.Errorf("digest %d (%s): schedule is required", i, d.Name)
+		}
+		if err := d.Match.Validate(); err != nil {
+			return fmt.Errorf("digest %d (%s) %v", i, d.Name, err)
+		}
+	}
+	return nil
+}
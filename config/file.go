@@ -0,0 +1,346 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"jellynotifier/routing"
+)
+
+// PathEnv names the environment variable holding the config file path
+const PathEnv = "JELLYNOTIFIER_CONFIG"
+
+// fileSchema is the layout of the config file. Every field points into a
+// Config so keys missing from the file keep their defaults. See
+// config.example.yaml for a documented example.
+type fileSchema struct {
+	Port            *string        `yaml:"port"`
+	DataDir         *string        `yaml:"data_dir"`
+	AdminToken      *string        `yaml:"admin_token"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"`
+	WatchInterval   *time.Duration `yaml:"watch_interval"`
+	TemplatesDir    *string        `yaml:"templates_dir"`
+
+	Discord struct {
+		Enabled   *bool   `yaml:"enabled"`
+		Token     *string `yaml:"token"`
+		ChannelID *string `yaml:"channel_id"`
+		DM        struct {
+			Enabled *bool     `yaml:"enabled"`
+			Events  *[]string `yaml:"events"`
+			OptOut  *[]string `yaml:"opt_out"`
+		} `yaml:"dm"`
+		MentionEvents    *[]string      `yaml:"mention_events"`
+		Routes           *routing.Table `yaml:"routes"`
+		EditMessages     *bool          `yaml:"edit_messages"`
+		NewMessageEvents *[]string      `yaml:"new_message_events"`
+		IssueThreads     *bool          `yaml:"issue_threads"`
+		Commands         struct {
+			Enabled *bool     `yaml:"enabled"`
+			GuildID *string   `yaml:"guild_id"`
+			Roles   *[]string `yaml:"roles"`
+		} `yaml:"commands"`
+		AdminRoles *[]string `yaml:"admin_roles"`
+	} `yaml:"discord"`
+
+	Overseerr struct {
+		URL    *string `yaml:"url"`
+		APIKey *string `yaml:"api_key"`
+	} `yaml:"overseerr"`
+
+	Slack struct {
+		Enabled    *bool   `yaml:"enabled"`
+		WebhookURL *string `yaml:"webhook_url"`
+	} `yaml:"slack"`
+
+	Telegram struct {
+		Enabled *bool   `yaml:"enabled"`
+		Token   *string `yaml:"token"`
+		ChatID  *string `yaml:"chat_id"`
+		APIURL  *string `yaml:"api_url"`
+		DMUsers *bool   `yaml:"dm_users"`
+	} `yaml:"telegram"`
+
+	Webhook struct {
+		Token           *string        `yaml:"token"`
+		TokenHeader     *string        `yaml:"token_header"`
+		HMACSecret      *string        `yaml:"hmac_secret"`
+		SignatureHeader *string        `yaml:"signature_header"`
+		TimestampHeader *string        `yaml:"timestamp_header"`
+		MaxSkew         *time.Duration `yaml:"max_skew"`
+	} `yaml:"webhook"`
+
+	Queue struct {
+		Size    *int `yaml:"size"`
+		Workers *int `yaml:"workers"`
+	} `yaml:"queue"`
+
+	Dedup struct {
+		Window  *time.Duration `yaml:"window"`
+		Size    *int           `yaml:"size"`
+		Persist *bool          `yaml:"persist"`
+	} `yaml:"dedup"`
+
+	Coalesce struct {
+		Window   *time.Duration `yaml:"window"`
+		MaxWait  *time.Duration `yaml:"max_wait"`
+		MaxItems *int           `yaml:"max_items"`
+		Events   *[]string      `yaml:"events"`
+	} `yaml:"coalesce"`
+
+	Digests *[]Digest `yaml:"digests"`
+
+	Retry struct {
+		MaxAttempts     *int           `yaml:"max_attempts"`
+		InitialInterval *time.Duration `yaml:"initial_interval"`
+		MaxInterval     *time.Duration `yaml:"max_interval"`
+		MaxElapsed      *time.Duration `yaml:"max_elapsed"`
+	} `yaml:"retry"`
+}
+
+// schema binds the file layout to the fields of c
+func (c *Config) schema() *fileSchema {
+	s := &fileSchema{
+		Port:            &c.Port,
+		DataDir:         &c.DataDir,
+		AdminToken:      &c.AdminToken,
+		ShutdownTimeout: &c.ShutdownTimeout,
+		WatchInterval:   &c.WatchInterval,
+		TemplatesDir:    &c.TemplatesDir,
+	}
+
+	s.Discord.Enabled = &c.EnableDiscord
+	s.Discord.Token = &c.DiscordToken
+	s.Discord.ChannelID = &c.DiscordChannel
+	s.Discord.DM.Enabled = &c.DiscordDMEnabled
+	s.Discord.DM.Events = &c.DiscordDMEvents
+	s.Discord.DM.OptOut = &c.DiscordDMOptOut
+	s.Discord.MentionEvents = &c.DiscordMentionEvents
+	s.Discord.Routes = &c.DiscordRoutes
+	s.Discord.EditMessages = &c.DiscordEditMessages
+	s.Discord.NewMessageEvents = &c.DiscordNewMessageEvents
+	s.Discord.IssueThreads = &c.DiscordIssueThreads
+	s.Discord.Commands.Enabled = &c.DiscordCommandsEnabled
+	s.Discord.Commands.GuildID = &c.DiscordCommandsGuildID
+	s.Discord.Commands.Roles = &c.DiscordCommandRoles
+	s.Discord.AdminRoles = &c.DiscordAdminRoles
+
+	s.Overseerr.URL = &c.OverseerrURL
+	s.Overseerr.APIKey = &c.OverseerrAPIKey
+
+	s.Slack.Enabled = &c.EnableSlack
+	s.Slack.WebhookURL = &c.SlackWebhookURL
+
+	s.Telegram.Enabled = &c.EnableTelegram
+	s.Telegram.Token = &c.TelegramToken
+	s.Telegram.ChatID = &c.TelegramChatID
+	s.Telegram.APIURL = &c.TelegramAPIURL
+	s.Telegram.DMUsers = &c.TelegramDMUsers
+
+	s.Webhook.Token = &c.WebhookToken
+	s.Webhook.TokenHeader = &c.WebhookTokenHeader
+	s.Webhook.HMACSecret = &c.WebhookHMACSecret
+	s.Webhook.SignatureHeader = &c.WebhookSignatureHeader
+	s.Webhook.TimestampHeader = &c.WebhookTimestampHeader
+	s.Webhook.MaxSkew = &c.WebhookMaxSkew
+
+	s.Queue.Size = &c.QueueSize
+	s.Queue.Workers = &c.QueueWorkers
+
+	s.Dedup.Window = &c.DedupWindow
+	s.Dedup.Size = &c.DedupSize
+	s.Dedup.Persist = &c.DedupPersist
+
+	s.Coalesce.Window = &c.CoalesceWindow
+	s.Coalesce.MaxWait = &c.CoalesceMaxWait
+	s.Coalesce.MaxItems = &c.CoalesceMaxItems
+	s.Coalesce.Events = &c.CoalesceEvents
+
+	s.Digests = &c.Digests
+
+	s.Retry.MaxAttempts = &c.RetryMaxAttempts
+	s.Retry.InitialInterval = &c.RetryInitialInterval
+	s.Retry.MaxInterval = &c.RetryMaxInterval
+	s.Retry.MaxElapsed = &c.RetryMaxElapsed
+	return s
+}
+
+// source remembers where each key of the config file was defined so
+// validation errors can point at it
+type source struct {
+	path string
+	root *yaml.Node
+	// lines is false when root was converted from another format, so its
+	// line numbers do not match the file
+	lines bool
+}
+
+// loadFile reads the YAML, JSON or TOML config file at path into cfg.
+// TOML is converted to YAML first so every format is decoded and checked
+// the same way.
+func loadFile(path string, cfg *Config) (*source, error) {
+	log.Printf("[DEBUG] [CONFIG] Reading config file %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	src := &source{path: path, root: &yaml.Node{}, lines: true}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		if data, err = tomlToYAML(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		src.lines = false
+	}
+	if err := yaml.Unmarshal(data, src.root); err != nil {
+		return nil, src.wrap(err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg.schema()); err != nil && !errors.Is(err, io.EOF) {
+		return nil, src.wrap(err)
+	}
+
+	log.Printf("[DEBUG] [CONFIG] Config file %s loaded", path)
+	return src, nil
+}
+
+// tomlToYAML re-encodes a TOML document as YAML
+func tomlToYAML(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	return yaml.Marshal(doc)
+}
+
+// yamlLine extracts the line number from a yaml.v3 error message
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// unknownField matches the KnownFields error, whose type name is unreadable
+// for the nested schema structs
+var unknownField = regexp.MustCompile(`^field (\S+) not found in type .*$`)
+
+// wrap rewrites yaml errors as "file:line: key.path: message"
+func (s *source) wrap(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return errors.New(s.locate(err.Error()))
+	}
+
+	messages := make([]string, 0, len(typeErr.Errors))
+	for _, message := range typeErr.Errors {
+		messages = append(messages, s.locate(message))
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// locate prefixes a single yaml error message with the file, line and key path
+func (s *source) locate(message string) string {
+	match := yamlLine.FindStringSubmatch(message)
+	if match == nil {
+		return fmt.Sprintf("%s: %s", s.path, strings.TrimPrefix(message, "yaml: "))
+	}
+	line, _ := strconv.Atoi(match[1])
+	message = unknownField.ReplaceAllString(strings.TrimPrefix(message, match[0]), "unknown key $1")
+	if key := keyAt(s.root, line, ""); key != "" {
+		message = key + ": " + message
+	}
+	if !s.lines {
+		return fmt.Sprintf("%s: %s", s.path, message)
+	}
+	return fmt.Sprintf("%s:%d: %s", s.path, line, message)
+}
+
+// errorf formats a validation error for the dotted key path, prefixed with
+// the file and line when the key was set in the config file
+func (s *source) errorf(key, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if s != nil {
+		if node := lookup(s.root, key); node != nil {
+			if !s.lines {
+				return fmt.Errorf("%s: %s: %s", s.path, key, message)
+			}
+			return fmt.Errorf("%s:%d: %s: %s", s.path, node.Line, key, message)
+		}
+	}
+	return fmt.Errorf("%s: %s", key, message)
+}
+
+// lookup returns the key node of a dotted key path, or nil
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	var keyNode *yaml.Node
+	for _, part := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		keyNode = nil
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == part {
+				keyNode, node = node.Content[i], node.Content[i+1]
+				break
+			}
+		}
+		if keyNode == nil {
+			return nil
+		}
+	}
+	return keyNode
+}
+
+// keyAt returns the dotted path of the deepest key defined on line
+func keyAt(node *yaml.Node, line int, prefix string) string {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if key := keyAt(child, line, prefix); key != "" {
+				return key
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := key.Value
+			if prefix != "" {
+				path = prefix + "." + key.Value
+			}
+			if nested := keyAt(value, line, path); nested != "" {
+				return nested
+			}
+			if key.Line == line || value.Line == line {
+				return path
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			path := fmt.Sprintf("%s[%d]", prefix, i)
+			if nested := keyAt(item, line, path); nested != "" {
+				return nested
+			}
+			if item.Line == line && item.Kind == yaml.ScalarNode {
+				return path
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watch calls changed whenever the contents of the given files, or of the
+// files in the given directories, change. It checks every interval until
+// ctx is cancelled. Contents are compared rather than modification times
+// because Kubernetes updates mounted ConfigMaps by swapping a symlink,
+// which can leave the mtime untouched.
+func Watch(ctx context.Context, interval time.Duration, changed func(), paths ...string) {
+	var watched []string
+	for _, path := range paths {
+		if path != "" {
+			watched = append(watched, path)
+		}
+	}
+	if len(watched) == 0 || interval <= 0 {
+		log.Println("[DEBUG] [CONFIG] Config file watching disabled")
+		return
+	}
+
+	log.Printf("[DEBUG] [CONFIG] Watching %s for changes every %s", strings.Join(watched, ", "), interval)
+	last, _ := checksum(watched)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[DEBUG] [CONFIG] Stopped watching config file")
+			return
+		case <-ticker.C:
+			sum, err := checksum(watched)
+			if err != nil {
+				// Mid-update or removed; keep the running configuration
+				log.Printf("[WARN] [CONFIG] Cannot read watched files: %v", err)
+				continue
+			}
+			if sum == last {
+				continue
+			}
+			last = sum
+			log.Println("[DEBUG] [CONFIG] Watched configuration changed")
+			changed()
+		}
+	}
+}
+
+// checksum hashes the contents of the files and of the regular files in
+// the directories. A missing directory hashes as empty.
+func checksum(paths []string) ([sha256.Size]byte, error) {
+	hash := sha256.New()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return [sha256.Size]byte{}, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return [sha256.Size]byte{}, err
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				// Skip the ..data style entries Kubernetes uses for ConfigMaps
+				if !strings.HasPrefix(entry.Name(), ".") {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				if info, statErr := os.Stat(file); statErr == nil && info.IsDir() {
+					continue
+				}
+				return [sha256.Size]byte{}, err
+			}
+			hash.Write([]byte(file))
+			hash.Write(data)
+		}
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,84 @@
+package deadletter
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/store"
+)
+
+// FileName is the name of the dead-letter file inside the data directory
+const FileName = "deadletters.json"
+
+// Letter is an event whose delivery failed for good
+type Letter struct {
+	ID    string       `json:"id"`
+	Event events.Event `json:"event"`
+	// Sink is the sink that failed; a redrive only targets this sink
+	Sink string `json:"sink"`
+	// Targets are the destinations within the sink that failed, such as
+	// Discord channels; a redrive only posts to these
+	Targets      []string  `json:"targets,omitempty"`
+	Error        string    `json:"error"`
+	Attempts     int       `json:"attempts"`
+	AcceptedAt   time.Time `json:"accepted_at"`
+	FirstAttempt time.Time `json:"first_attempt"`
+	LastAttempt  time.Time `json:"last_attempt"`
+}
+
+// Store keeps dead letters on disk until they are redriven or purged
+type Store struct {
+	letters *store.Map[Letter]
+}
+
+// Open loads the dead-letter store from dir
+func Open(dir string) (*Store, error) {
+	letters, err := store.Open[Letter](filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] [DEADLETTER] Dead-letter store opened with %d letters", letters.Len())
+	return &Store{letters: letters}, nil
+}
+
+// Add stores a dead letter, replacing any letter with the same ID
+func (s *Store) Add(letter Letter) error {
+	log.Printf("[WARN] [DEADLETTER] Dead-lettering %s after %d attempt(s): %s", letter.ID, letter.Attempts, letter.Error)
+	return s.letters.Put(letter.ID, letter)
+}
+
+// Get returns a single dead letter
+func (s *Store) Get(id string) (Letter, bool) {
+	return s.letters.Get(id)
+}
+
+// List returns all dead letters, oldest failure first
+func (s *Store) List() []Letter {
+	letters := s.letters.Values()
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].LastAttempt.Before(letters[j].LastAttempt)
+	})
+	return letters
+}
+
+// Delete removes a dead letter and reports whether it existed
+func (s *Store) Delete(id string) (bool, error) {
+	return s.letters.Delete(id)
+}
+
+// Purge removes every dead letter and returns how many were removed
+func (s *Store) Purge() (int, error) {
+	removed, err := s.letters.DeleteFunc(func(string, Letter) bool { return true })
+	if err == nil {
+		log.Printf("[DEBUG] [DEADLETTER] Purged %d dead letters", removed)
+	}
+	return removed, err
+}
+
+// Len returns the number of dead letters
+func (s *Store) Len() int {
+	return s.letters.Len()
+}
@@ -0,0 +1,174 @@
+package dedup
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/store"
+)
+
+// File is the name of the dedup file inside the data directory
+const File = "dedup.json"
+
+// Cache remembers the keys of recently accepted webhooks so repeats within
+// the window can be suppressed. It holds at most size keys, dropping the
+// least recently seen first, and optionally persists them so a restart
+// does not let a retry through.
+type Cache struct {
+	mu     sync.Mutex
+	window time.Duration
+	size   int
+	order  *list.List // of *entry, most recently seen first
+	items  map[string]*list.Element
+	seen   *store.Map[time.Time] // nil when not persisted
+
+	// now is the clock, replaceable for tests
+	now func() time.Time
+}
+
+// entry is a key and when it was first accepted
+type entry struct {
+	key  string
+	seen time.Time
+}
+
+// New creates an in-memory cache of at most size keys remembered for window
+func New(window time.Duration, size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		window: window,
+		size:   size,
+		order:  list.New(),
+		items:  make(map[string]*list.Element),
+		now:    time.Now,
+	}
+}
+
+// Open creates a cache persisted in dir, loading the keys still inside the window
+func Open(dir string, window time.Duration, size int) (*Cache, error) {
+	seen, err := store.Open[time.Time](filepath.Join(dir, File))
+	if err != nil {
+		return nil, err
+	}
+
+	c := New(window, size)
+	c.seen = seen
+
+	cutoff := c.now().Add(-window)
+	if _, err := seen.DeleteFunc(func(_ string, at time.Time) bool { return !at.After(cutoff) }); err != nil {
+		return nil, err
+	}
+
+	// Rebuild the LRU oldest first so the newest keys end up at the front
+	keys := seen.Keys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, _ := seen.Get(keys[i])
+		b, _ := seen.Get(keys[j])
+		return a.Before(b)
+	})
+	for _, key := range keys {
+		at, _ := seen.Get(key)
+		c.items[key] = c.order.PushFront(&entry{key: key, seen: at})
+	}
+	for c.order.Len() > c.size {
+		c.evict()
+	}
+
+	log.Printf("[DEBUG] [DEDUP] Loaded %d key(s) seen in the last %s", c.order.Len(), window)
+	return c, nil
+}
+
+// Claim records key and reports whether it is new. For a duplicate it
+// returns false and when the key was first seen.
+func (c *Cache) Claim(key string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry)
+		if now.Sub(e.seen) < c.window {
+			c.order.MoveToFront(element)
+			return e.seen, false
+		}
+		c.remove(element)
+	}
+
+	c.items[key] = c.order.PushFront(&entry{key: key, seen: now})
+	for c.order.Len() > c.size {
+		c.evict()
+	}
+	if c.seen != nil {
+		if err := c.seen.Put(key, now); err != nil {
+			log.Printf("[ERROR] [DEDUP] Failed to persist key: %v", err)
+		}
+	}
+	return now, true
+}
+
+// Release forgets key, so a webhook that was claimed but could not be
+// accepted is not suppressed when the sender retries it
+func (c *Cache) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of remembered keys
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// evict drops the least recently seen key
+func (c *Cache) evict() {
+	if oldest := c.order.Back(); oldest != nil {
+		c.remove(oldest)
+	}
+}
+
+// remove drops element from the LRU and the persisted keys
+func (c *Cache) remove(element *list.Element) {
+	e := c.order.Remove(element).(*entry)
+	delete(c.items, e.key)
+	if c.seen != nil {
+		if _, err := c.seen.Delete(e.key); err != nil {
+			log.Printf("[ERROR] [DEDUP] Failed to drop persisted key: %v", err)
+		}
+	}
+}
+
+// HeaderKey returns the cache key for an Idempotency-Key header value
+func HeaderKey(value string) string {
+	return "idempotency-key:" + value
+}
+
+// Key returns the content key of an event: a hash of its source, kind,
+// request, issue, TMDB ID, subject and comment. The comment is included so
+// two different comments on the same issue are not mistaken for a repeat.
+func Key(event events.Event) string {
+	parts := []string{
+		event.Source,
+		event.Kind,
+		event.RequestID,
+		event.Issue.ID,
+		event.Media.TmdbID,
+		event.Title,
+		event.Comment,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return "content:" + hex.EncodeToString(sum[:])
+}
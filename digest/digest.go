@@ -0,0 +1,276 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+	"jellynotifier/routing"
+	"jellynotifier/store"
+)
+
+// Config describes one digest: which notifications it collects, when it
+// is posted and through which sink
+type Config struct {
+	// Name identifies the digest; its sink is "digest-<name>" and its
+	// pages have the kind "digest.<name>" for routing
+	Name string
+	// Schedule is a cron expression such as "0 9 * * *" or "@weekly"
+	Schedule string
+	// Sink posts the digest, "discord" when empty
+	Sink string
+	// Title heads every page, "What's new" when empty
+	Title string
+	// Match selects the notifications collected; empty matches everything
+	Match routing.Match
+}
+
+// SinkName returns the name the digest is registered under
+func (c Config) SinkName() string {
+	return "digest-" + c.Name
+}
+
+// target returns the sink that posts the digest
+func (c Config) target() string {
+	if c.Sink == "" {
+		return "discord"
+	}
+	return c.Sink
+}
+
+// Clock tells the time and waits; tests replace it to drive the schedule
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the real clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock returns the wall clock
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// Sender posts events through named sinks; *notifier.Registry implements it
+type Sender interface {
+	SendTo(ctx context.Context, names []string, event events.Event) error
+}
+
+// Item is a collected notification
+type Item struct {
+	At    time.Time    `json:"at"`
+	Event events.Event `json:"event"`
+}
+
+// postTimeout bounds how long posting one digest may take, retries included
+const postTimeout = 5 * time.Minute
+
+// Sink wraps another sink: it collects matching notifications and posts
+// them through that sink as one grouped digest on its schedule. Collected
+// notifications are persisted, so a restart only delays them to the next
+// digest.
+type Sink struct {
+	cfg      Config
+	schedule Schedule
+	sender   Sender
+	clock    Clock
+	items    *store.Map[Item]
+
+	// mu guards started and seq, which keeps keys of the same instant unique
+	mu  sync.Mutex
+	seq int
+	// posting serialises Post; Send is not blocked by a slow post since a
+	// post only removes the items it included
+	posting sync.Mutex
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	done    chan struct{}
+}
+
+// Open creates the digest sink for cfg, loading the notifications it
+// collected before from dir. Call Start to begin posting on schedule.
+func Open(dir string, cfg Config, sender Sender, clock Clock) (*Sink, error) {
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("digest %s: %v", cfg.Name, err)
+	}
+	items, err := store.Open[Item](filepath.Join(dir, cfg.SinkName()+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = SystemClock()
+	}
+	log.Printf("[DEBUG] [DIGEST] Digest %s posts to %s on %q with %d collected notification(s)", cfg.Name, cfg.target(), cfg.Schedule, items.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Sink{
+		cfg:      cfg,
+		schedule: schedule,
+		sender:   sender,
+		clock:    clock,
+		items:    items,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Name implements notifier.Notifier
+func (s *Sink) Name() string {
+	return s.cfg.SinkName()
+}
+
+// Capabilities implements notifier.Notifier
+func (s *Sink) Capabilities() notifier.Capabilities {
+	return notifier.Capabilities{
+		Description: fmt.Sprintf("%s digest posted to %s on %q", s.cfg.Name, s.cfg.target(), s.cfg.Schedule),
+	}
+}
+
+// Send implements notifier.Notifier by collecting the event if it matches
+func (s *Sink) Send(ctx context.Context, event events.Event) error {
+	if event.Source == "digest" || !s.cfg.Match.Matches(event) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now().UTC()
+	s.seq++
+	key := fmt.Sprintf("%020d-%06d", now.UnixNano(), s.seq%1000000)
+	if err := s.items.Put(key, Item{At: now, Event: event}); err != nil {
+		return fmt.Errorf("error collecting %s for digest %s: %v", event.Kind, s.cfg.Name, err)
+	}
+	log.Printf("[DEBUG] [DIGEST] Collected %s for digest %s (%d pending)", event.Kind, s.cfg.Name, s.items.Len())
+	return nil
+}
+
+// Adopt replaces the notifications of s with those collected by old. A
+// digest rebuilt on reload shares its file with the one it replaces, which
+// may collect or post after s is opened; call Adopt once old is stopped.
+func (s *Sink) Adopt(old *Sink) error {
+	current := make(map[string]bool)
+	for _, key := range old.items.Keys() {
+		current[key] = true
+		if _, exists := s.items.Get(key); exists {
+			continue
+		}
+		if item, ok := old.items.Get(key); ok {
+			if err := s.items.Put(key, item); err != nil {
+				return fmt.Errorf("error taking over notifications of digest %s: %v", s.cfg.Name, err)
+			}
+		}
+	}
+	if _, err := s.items.DeleteFunc(func(key string, _ Item) bool { return !current[key] }); err != nil {
+		return fmt.Errorf("error taking over notifications of digest %s: %v", s.cfg.Name, err)
+	}
+	return nil
+}
+
+// Start posts the digest on its schedule until Stop is called
+func (s *Sink) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+}
+
+// Stop ends the schedule, interrupting a post in progress
+func (s *Sink) Stop() error {
+	s.cancel()
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if started {
+		<-s.done
+	}
+	return nil
+}
+
+// run waits for each scheduled time and posts the digest
+func (s *Sink) run() {
+	defer close(s.done)
+	for {
+		now := s.clock.Now()
+		next := s.schedule.Next(now)
+		if next.IsZero() {
+			log.Printf("[WARN] [DIGEST] Schedule %q of digest %s never fires", s.schedule, s.cfg.Name)
+			return
+		}
+		log.Printf("[DEBUG] [DIGEST] Next %s digest at %s", s.cfg.Name, next.Format(time.RFC3339))
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.clock.After(next.Sub(now)):
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, postTimeout)
+		if err := s.Post(ctx); err != nil {
+			log.Printf("[ERROR] [DIGEST] %v", err)
+		}
+		cancel()
+	}
+}
+
+// Post sends the collected notifications as a digest now and forgets
+// them. If a page fails they are kept for the next digest, which repeats
+// the pages already sent.
+func (s *Sink) Post(ctx context.Context) error {
+	s.posting.Lock()
+	defer s.posting.Unlock()
+
+	keys := s.items.Keys()
+	if len(keys) == 0 {
+		log.Printf("[DEBUG] [DIGEST] Nothing collected for digest %s, skipping", s.cfg.Name)
+		return nil
+	}
+	items := make([]Item, 0, len(keys))
+	for _, key := range keys {
+		if item, ok := s.items.Get(key); ok {
+			items = append(items, item)
+		}
+	}
+
+	title := s.cfg.Title
+	if title == "" {
+		title = "What's new"
+	}
+	pages := Pages("digest."+s.cfg.Name, title, s.clock.Now(), items)
+	if len(pages) == 0 {
+		log.Printf("[DEBUG] [DIGEST] None of the %d notification(s) collected for digest %s belongs in a section, dropping them", len(items), s.cfg.Name)
+	} else {
+		log.Printf("[DEBUG] [DIGEST] Posting digest %s: %d notification(s) on %d page(s)", s.cfg.Name, len(items), len(pages))
+	}
+	for i, page := range pages {
+		if err := s.sender.SendTo(ctx, []string{s.cfg.target()}, page); err != nil {
+			return fmt.Errorf("error posting page %d/%d of digest %s: %v", i+1, len(pages), s.cfg.Name, err)
+		}
+	}
+
+	posted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		posted[key] = true
+	}
+	if _, err := s.items.DeleteFunc(func(key string, _ Item) bool { return posted[key] }); err != nil {
+		return fmt.Errorf("error clearing digest %s: %v", s.cfg.Name, err)
+	}
+	if len(pages) > 0 {
+		log.Printf("Posted %s digest of %d notification(s)", s.cfg.Name, len(items))
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/routing"
+)
+
+// fakeClock stands still; each wait is reported on waits and ends when
+// fire is sent to
+type fakeClock struct {
+	now   time.Time
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waits: make(chan time.Duration, 10), fire: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits <- d
+	return c.fire
+}
+
+// fakeSender records the pages it is given and fails those numbered in fail
+type fakeSender struct {
+	mu    sync.Mutex
+	calls int
+	fail  map[int]bool
+	sinks []string
+	pages []events.Event
+	sent  chan struct{}
+}
+
+func (s *fakeSender) SendTo(ctx context.Context, names []string, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.fail[s.calls] {
+		return errors.New("sink unavailable")
+	}
+	s.sinks = append(s.sinks, names...)
+	s.pages = append(s.pages, event)
+	if s.sent != nil {
+		s.sent <- struct{}{}
+	}
+	return nil
+}
+
+func openSink(t *testing.T, cfg Config, sender Sender, clock Clock) *Sink {
+	t.Helper()
+	sink, err := Open(t.TempDir(), cfg, sender, clock)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { sink.Stop() })
+	return sink
+}
+
+func collect(t *testing.T, sink *Sink, items ...Item) {
+	t.Helper()
+	for _, item := range items {
+		if err := sink.Send(context.Background(), item.Event); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+}
+
+func TestPostKeepsItemsWhenAPageFails(t *testing.T) {
+	sender := &fakeSender{fail: map[int]bool{1: true}}
+	sink := openSink(t, Config{Name: "daily", Schedule: "0 9 * * *", Sink: "slack"}, sender, newFakeClock(at("2026-10-16 09:00")))
+	collect(t, sink, movie("Dune", "alice"), movie("Arrival", "bob"))
+
+	if err := sink.Post(context.Background()); err == nil {
+		t.Fatal("expected the failed page to be reported")
+	}
+	if n := sink.items.Len(); n != 2 {
+		t.Fatalf("%d item(s) kept after a failed post, want 2", n)
+	}
+
+	if err := sink.Post(context.Background()); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if n := sink.items.Len(); n != 0 {
+		t.Errorf("%d item(s) kept after posting, want 0", n)
+	}
+	if len(sender.pages) != 1 || !strings.Contains(sender.pages[0].Body, "Dune") || !strings.Contains(sender.pages[0].Body, "Arrival") {
+		t.Errorf("posted %+v, want one page with both movies", sender.pages)
+	}
+	if len(sender.sinks) != 1 || sender.sinks[0] != "slack" {
+		t.Errorf("posted through %v, want slack", sender.sinks)
+	}
+}
+
+func TestPostKeepsItemsWhenALaterPageFails(t *testing.T) {
+	sender := &fakeSender{fail: map[int]bool{2: true}}
+	sink := openSink(t, Config{Name: "daily", Schedule: "0 9 * * *"}, sender, newFakeClock(at("2026-10-16 09:00")))
+	for i := 0; i < 40; i++ {
+		collect(t, sink, movie(fmt.Sprintf("Movie %02d %s", i, strings.Repeat("x", 150)), ""))
+	}
+
+	if err := sink.Post(context.Background()); err == nil || !strings.Contains(err.Error(), "page 2/") {
+		t.Fatalf("Post error = %v, want page 2 to fail", err)
+	}
+	if n := sink.items.Len(); n != 40 {
+		t.Fatalf("%d item(s) kept after a failed post, want 40", n)
+	}
+	if len(sender.pages) != 1 {
+		t.Fatalf("%d page(s) posted before the failure, want 1", len(sender.pages))
+	}
+	if sender.sinks[0] != "discord" {
+		t.Errorf("posted through %q, want the default discord sink", sender.sinks[0])
+	}
+}
+
+func TestPostLeavesItemsCollectedMeanwhile(t *testing.T) {
+	sink := openSink(t, Config{Name: "daily", Schedule: "0 9 * * *"}, nil, newFakeClock(at("2026-10-16 09:00")))
+	sender := &fakeSender{}
+	sink.sender = senderFunc(func(ctx context.Context, names []string, event events.Event) error {
+		// Arrives while the digest is being posted
+		collect(t, sink, movie("Late", ""))
+		return sender.SendTo(ctx, names, event)
+	})
+	collect(t, sink, movie("Dune", ""))
+
+	if err := sink.Post(context.Background()); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	items := sink.items.Values()
+	if len(items) != 1 || items[0].Event.Media.Title != "Late" {
+		t.Errorf("kept %+v, want only the late movie", items)
+	}
+}
+
+// senderFunc adapts a function to Sender
+type senderFunc func(ctx context.Context, names []string, event events.Event) error
+
+func (f senderFunc) SendTo(ctx context.Context, names []string, event events.Event) error {
+	return f(ctx, names, event)
+}
+
+func TestPostWithNothingCollected(t *testing.T) {
+	sender := &fakeSender{}
+	sink := openSink(t, Config{Name: "daily", Schedule: "0 9 * * *"}, sender, newFakeClock(at("2026-10-16 09:00")))
+	collect(t, sink, Item{Event: events.Event{Kind: "issue.created", Issue: events.Issue{ID: "1"}}})
+
+	if err := sink.Post(context.Background()); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if len(sender.pages) != 0 {
+		t.Errorf("posted %d page(s), want none", len(sender.pages))
+	}
+	if n := sink.items.Len(); n != 0 {
+		t.Errorf("%d item(s) kept that belong in no section, want 0", n)
+	}
+}
+
+func TestSendCollectsMatchingEvents(t *testing.T) {
+	cfg := Config{Name: "movies", Schedule: "@daily", Match: routing.Match{MediaType: []string{"movie"}}}
+	sink := openSink(t, cfg, &fakeSender{}, newFakeClock(at("2026-10-16 09:00")))
+
+	collect(t, sink,
+		movie("Dune", ""),
+		episodes("Severance", 1, 1),
+		Item{Event: events.Event{Source: "digest", Kind: "digest.other", Media: events.Media{Type: "movie"}}},
+	)
+	if n := sink.items.Len(); n != 1 {
+		t.Errorf("collected %d item(s), want only the movie", n)
+	}
+}
+
+func TestOpenKeepsCollectedItems(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Name: "daily", Schedule: "0 9 * * *"}
+	sink, err := Open(dir, cfg, &fakeSender{}, newFakeClock(at("2026-10-16 09:00")))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	collect(t, sink, movie("Dune", ""), movie("Arrival", ""))
+
+	reopened, err := Open(dir, cfg, &fakeSender{}, newFakeClock(at("2026-10-16 09:00")))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if n := reopened.items.Len(); n != 2 {
+		t.Errorf("reopened digest has %d item(s), want 2", n)
+	}
+}
+
+func TestStartPostsOnSchedule(t *testing.T) {
+	clock := newFakeClock(at("2026-10-16 08:30"))
+	sender := &fakeSender{sent: make(chan struct{}, 1)}
+	sink := openSink(t, Config{Name: "daily", Schedule: "0 9 * * *"}, sender, clock)
+	collect(t, sink, movie("Dune", ""))
+
+	sink.Start()
+	if wait := <-clock.waits; wait != 30*time.Minute {
+		t.Errorf("waiting %s for the digest, want 30m", wait)
+	}
+	clock.fire <- clock.now
+
+	select {
+	case <-sender.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest was not posted at its scheduled time")
+	}
+	// The next wait begins once the post is done
+	<-clock.waits
+	if n := sink.items.Len(); n != 0 {
+		t.Errorf("%d item(s) kept after the scheduled post, want 0", n)
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	sink, err := Open(t.TempDir(), Config{Name: "daily", Schedule: "@daily"}, &fakeSender{}, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		sink.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a digest that was never started")
+	}
+}
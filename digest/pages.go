@@ -0,0 +1,232 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"jellynotifier/coalesce"
+	"jellynotifier/events"
+)
+
+// Page limits keep every page inside the smallest sink limit: Discord
+// allows 4096 characters in an embed description and 6000 per embed,
+// Telegram 4096 per message before MarkdownV2 escaping
+const (
+	maxPageLength   = 3000
+	maxLineLength   = 200
+	maxTopRequester = 5
+)
+
+// section is a titled list of lines in a digest
+type section struct {
+	title string
+	lines []string
+}
+
+// Pages builds the digest of items posted at the given time. Movies, shows,
+// resolved issues and top requesters each get a section; sections are split
+// across as many pages as needed to stay within the sink limits, and the
+// first page carries the totals as attributes. It returns nil when no item
+// belongs in a section.
+func Pages(kind, title string, at time.Time, items []Item) []events.Event {
+	movies, shows, issues, requesters := group(items)
+
+	sections := []section{
+		{title: fmt.Sprintf("🎬 Movies (%d)", len(movies)), lines: movies},
+		{title: fmt.Sprintf("📺 Shows (%d)", len(shows)), lines: shows},
+		{title: fmt.Sprintf("✅ Resolved issues (%d)", len(issues)), lines: issues},
+		{title: "🙋 Top requesters", lines: requesters},
+	}
+
+	var bodies []string
+	var page strings.Builder
+	for _, s := range sections {
+		for i, line := range s.lines {
+			line = "• " + truncate(line, maxLineLength)
+			need := len(line) + 1
+			if i == 0 {
+				need += len(s.title) + 2
+			}
+			if page.Len() > 0 && page.Len()+need > maxPageLength {
+				bodies = append(bodies, page.String())
+				page.Reset()
+			}
+
+			switch {
+			case i == 0:
+				if page.Len() > 0 {
+					page.WriteString("\n\n")
+				}
+				page.WriteString(s.title + "\n")
+			case page.Len() == 0:
+				page.WriteString(s.title + " (cont.)\n")
+			default:
+				page.WriteString("\n")
+			}
+			page.WriteString(line)
+		}
+	}
+	if page.Len() > 0 {
+		bodies = append(bodies, page.String())
+	}
+	if len(bodies) == 0 {
+		return nil
+	}
+
+	heading := fmt.Sprintf("%s — %s", title, at.Format("Mon, Jan 2"))
+	pages := make([]events.Event, 0, len(bodies))
+	for i, body := range bodies {
+		event := events.Event{
+			Source: "digest",
+			Kind:   kind,
+			Title:  heading,
+			Body:   body,
+		}
+		if len(bodies) > 1 {
+			event.Title = fmt.Sprintf("%s (%d/%d)", heading, i+1, len(bodies))
+		}
+		if i == 0 {
+			event.SetAttr("Movies", strconv.Itoa(len(movies)))
+			event.SetAttr("Shows", strconv.Itoa(len(shows)))
+			event.SetAttr("Resolved issues", strconv.Itoa(len(issues)))
+		}
+		pages = append(pages, event)
+	}
+	return pages
+}
+
+// series collects the episodes of one show
+type series struct {
+	title   string
+	seasons map[int]map[int]bool
+}
+
+// group sorts items into movie, show and resolved issue lines in arrival
+// order, with repeats of the same title merged, and ranks the requesters
+func group(items []Item) (movies, shows, issues, requesters []string) {
+	seenMovies := make(map[string]bool)
+	var showOrder []*series
+	showsByTitle := make(map[string]*series)
+	requests := make(map[string]int)
+
+	for _, item := range items {
+		event := item.Event
+		name := event.Media.Title
+		if name == "" {
+			name = event.Title
+		}
+
+		if event.Kind == "issue.resolved" {
+			line := name
+			if event.Issue.Type != "" {
+				line += " — " + event.Issue.Type + " issue"
+			}
+			if reporter := event.Reporter().Name; reporter != "" {
+				line += " (reported by " + reporter + ")"
+			}
+			issues = append(issues, line)
+			continue
+		}
+		if strings.HasPrefix(event.Kind, "issue.") || strings.HasPrefix(event.Kind, "comment.") {
+			continue
+		}
+
+		switch event.Media.Type {
+		case "movie":
+			if key := strings.ToLower(name); !seenMovies[key] {
+				seenMovies[key] = true
+				movies = append(movies, name)
+			}
+		case "tv":
+			key := strings.ToLower(name)
+			show, ok := showsByTitle[key]
+			if !ok {
+				show = &series{title: name, seasons: make(map[int]map[int]bool)}
+				showsByTitle[key] = show
+				showOrder = append(showOrder, show)
+			}
+			if len(event.Media.Episodes) > 0 {
+				if show.seasons[event.Media.Season] == nil {
+					show.seasons[event.Media.Season] = make(map[int]bool)
+				}
+				for _, episode := range event.Media.Episodes {
+					show.seasons[event.Media.Season][episode] = true
+				}
+			}
+		default:
+			continue
+		}
+
+		if requester := event.Requester().Name; requester != "" {
+			requests[requester]++
+		}
+	}
+
+	for _, show := range showOrder {
+		shows = append(shows, show.line())
+	}
+	return movies, shows, issues, rank(requests)
+}
+
+// line formats a show as "Show — S01 episodes 1–8, S02 episode 1"
+func (s *series) line() string {
+	if len(s.seasons) == 0 {
+		return s.title
+	}
+	numbers := make([]int, 0, len(s.seasons))
+	for season := range s.seasons {
+		numbers = append(numbers, season)
+	}
+	sort.Ints(numbers)
+
+	parts := make([]string, 0, len(numbers))
+	for _, season := range numbers {
+		episodes := make([]int, 0, len(s.seasons[season]))
+		for episode := range s.seasons[season] {
+			episodes = append(episodes, episode)
+		}
+		sort.Ints(episodes)
+		noun := "episodes"
+		if len(episodes) == 1 {
+			noun = "episode"
+		}
+		parts = append(parts, fmt.Sprintf("S%02d %s %s", season, noun, coalesce.Ranges(episodes)))
+	}
+	return s.title + " — " + strings.Join(parts, ", ")
+}
+
+// rank returns the requesters with the most notifications, most first
+func rank(requests map[string]int) []string {
+	names := make([]string, 0, len(requests))
+	for name := range requests {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if requests[names[i]] != requests[names[j]] {
+			return requests[names[i]] > requests[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxTopRequester {
+		names = names[:maxTopRequester]
+	}
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s (%d)", name, requests[name]))
+	}
+	return lines
+}
+
+// truncate shortens s to at most max runes, ending in an ellipsis
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,153 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"jellynotifier/events"
+)
+
+func movie(title, requester string) Item {
+	event := events.Event{Source: "overseerr", Kind: "media.available", Media: events.Media{Type: "movie", Title: title}}
+	event.AddActor(events.Actor{Role: events.RoleRequester, Name: requester})
+	return Item{Event: event}
+}
+
+func episodes(title string, season int, numbers ...int) Item {
+	return Item{Event: events.Event{Source: "sonarr", Kind: "download.completed", Media: events.Media{Type: "tv", Title: title, Season: season, Episodes: numbers}}}
+}
+
+func TestPagesGroupsItems(t *testing.T) {
+	resolved := events.Event{Source: "overseerr", Kind: "issue.resolved", Title: "Dune", Issue: events.Issue{ID: "3", Type: "Audio"}}
+	resolved.AddActor(events.Actor{Role: events.RoleReporter, Name: "carol"})
+	items := []Item{
+		movie("Dune", "alice"),
+		episodes("Severance", 2, 1, 2),
+		movie("Arrival", "bob"),
+		episodes("Severance", 2, 3),
+		episodes("Severance", 1, 9),
+		movie("dune", "alice"),
+		{Event: resolved},
+		{Event: events.Event{Source: "overseerr", Kind: "issue.created", Title: "Arrival", Issue: events.Issue{ID: "4"}}},
+	}
+
+	pages := Pages("digest.daily", "What's new", at("2026-10-16 09:00"), items)
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	page := pages[0]
+	if page.Source != "digest" || page.Kind != "digest.daily" {
+		t.Errorf("source and kind = %q, %q", page.Source, page.Kind)
+	}
+	if page.Title != "What's new — Fri, Oct 16" {
+		t.Errorf("title = %q", page.Title)
+	}
+
+	want := strings.Join([]string{
+		"🎬 Movies (2)",
+		"• Dune",
+		"• Arrival",
+		"",
+		"📺 Shows (1)",
+		"• Severance — S01 episode 9, S02 episodes 1–3",
+		"",
+		"✅ Resolved issues (1)",
+		"• Dune — Audio issue (reported by carol)",
+		"",
+		"🙋 Top requesters",
+		"• alice (2)",
+		"• bob (1)",
+	}, "\n")
+	if page.Body != want {
+		t.Errorf("body =\n%s\nwant\n%s", page.Body, want)
+	}
+
+	for name, value := range map[string]string{"Movies": "2", "Shows": "1", "Resolved issues": "1"} {
+		if got := page.Attr(name); got != value {
+			t.Errorf("attribute %s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestPagesWithoutSections(t *testing.T) {
+	if pages := Pages("digest.daily", "What's new", at("2026-10-16 09:00"), nil); pages != nil {
+		t.Errorf("got %d pages for no items, want none", len(pages))
+	}
+
+	items := []Item{{Event: events.Event{Kind: "issue.created", Issue: events.Issue{ID: "1"}}}}
+	if pages := Pages("digest.daily", "What's new", at("2026-10-16 09:00"), items); pages != nil {
+		t.Errorf("got %d pages for items without a section, want none", len(pages))
+	}
+}
+
+func TestPagesSplitsAtLimit(t *testing.T) {
+	const count = 100
+	items := make([]Item, 0, count)
+	for i := 0; i < count; i++ {
+		items = append(items, movie(fmt.Sprintf("Movie %03d %s", i, strings.Repeat("x", 150)), ""))
+	}
+
+	pages := Pages("digest.weekly", "Weekly", at("2026-10-16 09:00"), items)
+	if len(pages) < 2 {
+		t.Fatalf("got %d pages, want several", len(pages))
+	}
+
+	lines := 0
+	for i, page := range pages {
+		if n := len(page.Body); n > maxPageLength {
+			t.Errorf("page %d is %d bytes, over %d", i+1, n, maxPageLength)
+		}
+		if want := fmt.Sprintf("Weekly — Fri, Oct 16 (%d/%d)", i+1, len(pages)); page.Title != want {
+			t.Errorf("page %d title = %q, want %q", i+1, page.Title, want)
+		}
+		header := "🎬 Movies (100)\n"
+		if i > 0 {
+			header = "🎬 Movies (100) (cont.)\n"
+		}
+		if !strings.HasPrefix(page.Body, header) {
+			t.Errorf("page %d starts with %q, want %q", i+1, strings.SplitN(page.Body, "\n", 2)[0], header)
+		}
+		if got := page.Attr("Movies"); (i == 0) != (got == "100") {
+			t.Errorf("page %d has Movies attribute %q", i+1, got)
+		}
+		lines += strings.Count(page.Body, "• ")
+	}
+	if lines != count {
+		t.Errorf("pages list %d movies, want %d", lines, count)
+	}
+}
+
+func TestPagesTruncatesLongLines(t *testing.T) {
+	items := []Item{movie(strings.Repeat("é", 500), "")}
+
+	pages := Pages("digest.daily", "What's new", at("2026-10-16 09:00"), items)
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	line := strings.TrimPrefix(strings.Split(pages[0].Body, "\n")[1], "• ")
+	if n := utf8.RuneCountInString(line); n != maxLineLength {
+		t.Errorf("line is %d runes, want %d", n, maxLineLength)
+	}
+	if !strings.HasSuffix(line, "…") {
+		t.Errorf("truncated line %q does not end in an ellipsis", line)
+	}
+}
+
+func TestPagesRanksTopRequesters(t *testing.T) {
+	var items []Item
+	for i, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		for n := 0; n <= i; n++ {
+			items = append(items, movie(fmt.Sprintf("%s %d", name, n), name))
+		}
+	}
+
+	pages := Pages("digest.daily", "What's new", at("2026-10-16 09:00"), items)
+	body := pages[len(pages)-1].Body
+	requesters := body[strings.Index(body, "🙋 Top requesters"):]
+	want := "🙋 Top requesters\n• g (7)\n• f (6)\n• e (5)\n• d (4)\n• c (3)"
+	if requesters != want {
+		t.Errorf("requesters =\n%s\nwant\n%s", requesters, want)
+	}
+}
@@ -0,0 +1,154 @@
+package digest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron expression: minute, hour, day of month, month and
+// day of week, each "*", a number, a range "a-b", a step "*/n" or "a-b/n",
+// or a comma-separated list of those. @hourly, @daily, @weekly and
+// @monthly are accepted as shorthands. Times are in the local time zone.
+type Schedule struct {
+	spec   string
+	fields [5]uint64 // bit n set when value n matches
+	// anyDay is true when day of month or day of week is "*"; otherwise
+	// either one matching is enough, as in cron
+	anyDay bool
+}
+
+// shorthands maps the @ names to their expressions
+var shorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// bounds are the allowed values of each field
+var bounds = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron expression
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	expr := spec
+	if expanded, ok := shorthands[strings.ToLower(spec)]; ok {
+		expr = expanded
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return Schedule{}, fmt.Errorf("schedule %q must have 5 fields (minute hour day month weekday)", spec)
+	}
+
+	s := Schedule{spec: spec}
+	for i, part := range parts {
+		bits, err := parseField(part, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("schedule %q: %s: %v", spec, bounds[i].name, err)
+		}
+		s.fields[i] = bits
+	}
+	// Sunday is both 0 and 7
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	s.anyDay = parts[2] == "*" || parts[4] == "*"
+	return s, nil
+}
+
+// parseField parses one field into a bit set
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after t that matches the schedule, or the
+// zero time if none does within five years
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !s.has(3, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.has(1, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.has(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// has reports whether value is set in field i
+func (s Schedule) has(i, value int) bool {
+	return s.fields[i]&(1<<uint(value)) != 0
+}
+
+// day reports whether t's day matches the day of month and day of week fields
+func (s Schedule) day(t time.Time) bool {
+	dom := s.has(2, t.Day())
+	dow := s.has(4, int(t.Weekday()))
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,103 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"0 9 * * *", "2026-10-16 08:59", "2026-10-16 09:00"},
+		{"0 9 * * *", "2026-10-16 09:00", "2026-10-17 09:00"},
+		{"30 * * * *", "2026-10-16 10:30", "2026-10-16 11:30"},
+		{"*/15 9-17/4 * * *", "2026-10-16 09:50", "2026-10-16 13:00"},
+		{"0 8,18 * * *", "2026-10-16 12:00", "2026-10-16 18:00"},
+
+		// Month and year rollover
+		{"0 9 * * *", "2026-01-31 10:00", "2026-02-01 09:00"},
+		{"0 0 1 * *", "2026-01-15 00:00", "2026-02-01 00:00"},
+		{"@monthly", "2026-12-05 12:00", "2027-01-01 00:00"},
+		{"0 0 31 * *", "2026-04-01 00:00", "2026-05-31 00:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+
+		// Day of week, with Sunday as 0 or 7
+		{"0 0 * * 0", "2026-10-16 00:00", "2026-10-18 00:00"},
+		{"0 0 * * 7", "2026-10-16 00:00", "2026-10-18 00:00"},
+		{"@weekly", "2026-10-16 00:00", "2026-10-18 00:00"},
+		{"0 0 * * 1-5", "2026-10-16 00:00", "2026-10-19 00:00"},
+
+		// A restricted day of month and day of week match either
+		{"0 0 13 * 5", "2026-10-01 00:00", "2026-10-02 00:00"},
+		{"0 0 13 * 5", "2026-10-09 00:00", "2026-10-13 00:00"},
+		{"0 0 13 * 5", "2026-10-13 00:00", "2026-10-16 00:00"},
+		// but with the other one "*" only the restricted one counts
+		{"0 0 13 * *", "2026-10-13 00:00", "2026-11-13 00:00"},
+		{"0 0 * * 5", "2026-10-13 00:00", "2026-10-16 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" from "+tt.from, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule: %v", err)
+			}
+			if got := schedule.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+				t.Errorf("Next = %s, want %s", got.Format("2006-01-02 15:04 Mon"), tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextNever(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if got := schedule.Next(at("2026-01-01 00:00")); !got.IsZero() {
+		t.Errorf("Next = %s, want the zero time", got)
+	}
+}
+
+func TestParseScheduleRejects(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestScheduleString(t *testing.T) {
+	schedule, err := ParseSchedule(" @Daily ")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if schedule.String() != "@Daily" {
+		t.Errorf("String = %q, want %q", schedule.String(), "@Daily")
+	}
+}
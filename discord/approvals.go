@@ -0,0 +1,170 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+)
+
+// approvalTimeout bounds the Overseerr call made for a button click
+const approvalTimeout = 15 * time.Second
+
+// approvalPrefix starts the custom ID of the approve and decline buttons
+const approvalPrefix = "approval:"
+
+// approvalEvents are the events whose posts get approve and decline buttons
+var approvalEvents = []string{"media.pending", "media.requested"}
+
+// Approver approves and declines media requests, e.g. the Overseerr API
+type Approver interface {
+	Approve(ctx context.Context, requestID string) error
+	Decline(ctx context.Context, requestID string) error
+}
+
+// approvalButtons returns the approve and decline buttons for a pending
+// request, or nil when the notification does not get any
+func (b *Bot) approvalButtons(event events.Event) []discordgo.MessageComponent {
+	if b.current().opts.Approver == nil || event.RequestID == "" || !containsFold(approvalEvents, event.Kind) {
+		return nil
+	}
+	id := event.RequestID
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Approve",
+				Style:    discordgo.SuccessButton,
+				CustomID: approvalPrefix + "approve:" + id,
+				Emoji:    discordgo.ComponentEmoji{Name: "✅"},
+			},
+			discordgo.Button{
+				Label:    "Decline",
+				Style:    discordgo.DangerButton,
+				CustomID: approvalPrefix + "decline:" + id,
+				Emoji:    discordgo.ComponentEmoji{Name: "❌"},
+			},
+		}},
+	}
+}
+
+// onButton handles a click on an approve or decline button. Only members
+// with an admin role may act; the message is acknowledged first because
+// Discord expects an answer within three seconds.
+func (b *Bot) onButton(interaction *discordgo.InteractionCreate) {
+	action, requestID, ok := parseApprovalID(interaction.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	opts := b.current().opts
+	user := interactionUser(interaction.Interaction)
+	log.Printf("[DEBUG] [DISCORD] %s of request %s clicked by user %s", action, requestID, user)
+
+	switch {
+	case opts.Approver == nil:
+		b.reply(interaction.Interaction, notice("Approving requests from Discord is disabled."))
+		return
+	case !memberAllowed(interaction.Interaction, opts.AdminRoles):
+		log.Printf("[WARN] [DISCORD] Denied %s of request %s to user %s", action, requestID, user)
+		b.reply(interaction.Interaction, notice("Only admins can approve or decline requests."))
+		return
+	}
+
+	err := b.session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to acknowledge %s of request %s: %v", action, requestID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), approvalTimeout)
+	defer cancel()
+	if action == "approve" {
+		err = opts.Approver.Approve(ctx, requestID)
+	} else {
+		err = opts.Approver.Decline(ctx, requestID)
+	}
+	if err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to %s request %s: %v", action, requestID, err)
+		_, followErr := b.session.FollowupMessageCreate(interaction.Interaction, false, &discordgo.WebhookParams{
+			Embeds: []*discordgo.MessageEmbed{notice(fmt.Sprintf("Could not %s request %s: %v", action, requestID, err))},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		})
+		if followErr != nil {
+			log.Printf("[ERROR] [DISCORD] Failed to report the error to user %s: %v", user, followErr)
+		}
+		return
+	}
+	log.Printf("Request %s %sd from Discord by user %s", requestID, action, user)
+
+	embeds := decidedEmbeds(interaction.Message, action, user)
+	components := []discordgo.MessageComponent{}
+	if _, err := b.session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &components,
+	}); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to update the message for request %s: %v", requestID, err)
+	}
+}
+
+// decidedEmbeds returns the message's embeds with who approved or declined
+// the request added to the first one
+func decidedEmbeds(message *discordgo.Message, action, userID string) []*discordgo.MessageEmbed {
+	var embeds []*discordgo.MessageEmbed
+	if message != nil {
+		embeds = append(embeds, message.Embeds...)
+	}
+	if len(embeds) == 0 {
+		embeds = append(embeds, &discordgo.MessageEmbed{})
+	}
+
+	name, event := "✅ Approved", "media.approved"
+	if action == "decline" {
+		name, event = "❌ Declined", "media.declined"
+	}
+
+	first := *embeds[0]
+	fields := first.Fields
+	if len(fields) >= maxFields {
+		fields = fields[:maxFields-1]
+	}
+	first.Fields = append(append([]*discordgo.MessageEmbedField(nil), fields...), &discordgo.MessageEmbedField{
+		Name:  name,
+		Value: fmt.Sprintf("by <@%s> <t:%d:R>", userID, time.Now().Unix()),
+	})
+	first.Color = notifier.ColorForEvent(event)
+	embeds[0] = &first
+	return embeds
+}
+
+// parseApprovalID splits "approval:approve:42" into its action and request ID
+func parseApprovalID(customID string) (action, requestID string, ok bool) {
+	rest, found := strings.CutPrefix(customID, approvalPrefix)
+	if !found {
+		return "", "", false
+	}
+	action, requestID, found = strings.Cut(rest, ":")
+	if !found || requestID == "" || (action != "approve" && action != "decline") {
+		return "", "", false
+	}
+	return action, requestID, true
+}
+
+// reply answers an interaction with a message only the clicker sees
+func (b *Bot) reply(interaction *discordgo.Interaction, embed *discordgo.MessageEmbed) {
+	err := b.session.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to answer interaction from user %s: %v", interactionUser(interaction), err)
+	}
+}
@@ -0,0 +1,29 @@
+package discord
+
+import "testing"
+
+func TestParseApprovalID(t *testing.T) {
+	tests := []struct {
+		customID  string
+		action    string
+		requestID string
+		ok        bool
+	}{
+		{"approval:approve:42", "approve", "42", true},
+		{"approval:decline:7", "decline", "7", true},
+		{"approval:delete:42", "", "", false},
+		{"approval:approve:", "", "", false},
+		{"approval:approve", "", "", false},
+		{"other:approve:42", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.customID, func(t *testing.T) {
+			action, requestID, ok := parseApprovalID(tt.customID)
+			if action != tt.action || requestID != tt.requestID || ok != tt.ok {
+				t.Errorf("parseApprovalID(%q) = %q, %q, %t; want %q, %q, %t", tt.customID, action, requestID, ok, tt.action, tt.requestID, tt.ok)
+			}
+		})
+	}
+}
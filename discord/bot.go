@@ -1,23 +1,90 @@
 package discord
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"jellynotifier/models"
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+	"jellynotifier/retry"
+	"jellynotifier/routing"
+	"jellynotifier/templates"
 )
 
 // Bot represents the Discord bot instance
 type Bot struct {
-	session   *discordgo.Session
+	session  *discordgo.Session
+	settings atomic.Pointer[settings]
+
+	tracker *Tracker
+	// trackLocks serialises the posts of one tracked request or issue
+	trackLocks keyedMutex
+
+	dmMu       sync.Mutex
+	dmChannels map[string]string
+
+	commandsMu     sync.Mutex
+	controller     Controller
+	appID          string
+	registered     commandScope
+	commandsSynced bool
+}
+
+// Options holds the optional Discord bot behaviours
+type Options struct {
+	// DMEnabled sends requesters a personal message on DMEvents
+	DMEnabled bool
+	// DMEvents lists the events that trigger a DM to the requester
+	DMEvents []string
+	// DMOptOut lists Discord user IDs that never receive DMs
+	DMOptOut []string
+	// MentionEvents lists the events whose channel posts @mention the
+	// requester, reporter or commenter
+	MentionEvents []string
+	// Routes picks the channels for each notification; notifications no
+	// rule matches go to the table's default route, or the bot's channel
+	Routes routing.Table
+	// Templates replace the built-in embed layout for the events they cover
+	Templates *templates.Set
+	// EditMessages edits the message posted for a request as its status
+	// changes instead of posting a new one; it needs a Tracker
+	EditMessages bool
+	// NewMessageEvents always post a new message even when EditMessages is on
+	NewMessageEvents []string
+	// IssueThreads starts a thread on each issue.created post and sends the
+	// issue's comments and status changes into it; it needs a Tracker
+	IssueThreads bool
+	// Commands registers the /jellynotifier slash command, globally or in
+	// CommandsGuildID only; CommandRoles may use it, or administrators
+	// when no roles are set
+	Commands        bool
+	CommandsGuildID string
+	CommandRoles    []string
+	// Approver adds approve and decline buttons to pending requests;
+	// members with one of AdminRoles, or administrators when none are
+	// set, may click them
+	Approver   Approver
+	AdminRoles []string
+}
+
+// settings are the parts of the bot that can change without reconnecting
+type settings struct {
 	channelID string
+	policy    retry.Policy
+	opts      Options
 }
 
-// NewBot creates a new Discord bot instance
-func NewBot(token, channelID string) (*Bot, error) {
+// NewBot creates a new Discord bot instance that retries failed sends according to policy.
+// The tracker persists posted messages for editing; it may be nil.
+func NewBot(token, channelID string, policy retry.Policy, opts Options, tracker *Tracker) (*Bot, error) {
 	log.Println("[DEBUG] [DISCORD] Creating new Discord bot instance...")
 
 	if token == "" {
@@ -38,11 +105,39 @@ func NewBot(token, channelID string) (*Bot, error) {
 		return nil, fmt.Errorf("error creating Discord session: %v", err)
 	}
 
+	// Surface rate limits to our retry policy instead of sleeping inside discordgo
+	dg.ShouldRetryOnRateLimit = false
+
 	log.Println("[DEBUG] [DISCORD] Discord session created successfully")
-	return &Bot{
-		session:   dg,
-		channelID: channelID,
-	}, nil
+	log.Printf("[DEBUG] [DISCORD] Retry policy - Max attempts: %d, Max elapsed: %s", policy.MaxAttempts, policy.MaxElapsed)
+	log.Printf("[DEBUG] [DISCORD] DMs enabled: %t (events: %s, opted out: %d users)", opts.DMEnabled, strings.Join(opts.DMEvents, ","), len(opts.DMOptOut))
+	bot := &Bot{
+		session:    dg,
+		tracker:    tracker,
+		dmChannels: make(map[string]string),
+	}
+	bot.settings.Store(&settings{channelID: channelID, policy: policy, opts: opts})
+	dg.AddHandler(bot.onReady)
+	dg.AddHandler(bot.onInteraction)
+	return bot, nil
+}
+
+// Reconfigure swaps the channel, retry policy and options in place, keeping
+// the Discord connection open. Sends already in progress finish with the
+// settings they started with.
+func (b *Bot) Reconfigure(channelID string, policy retry.Policy, opts Options) error {
+	if channelID == "" {
+		return fmt.Errorf("discord channel ID is required")
+	}
+	b.settings.Store(&settings{channelID: channelID, policy: policy, opts: opts})
+	log.Printf("[DEBUG] [DISCORD] Reconfigured - channel: %s, DMs enabled: %t, routes: %d rules", channelID, opts.DMEnabled, len(opts.Routes.Rules))
+	b.syncCommands()
+	return nil
+}
+
+// current returns the active settings
+func (b *Bot) current() *settings {
+	return b.settings.Load()
 }
 
 // Start opens the Discord connection and waits for it to be ready
@@ -80,32 +175,126 @@ func (b *Bot) Stop() error {
 	return nil
 }
 
-// SendNotification sends a formatted notification to the Discord channel
-func (b *Bot) SendNotification(notification models.Notification) error {
-	log.Printf("[DEBUG] [DISCORD] Preparing to send notification - Type: %s, Event: %s", notification.NotificationType, notification.Event)
+// Name identifies the Discord sink
+func (b *Bot) Name() string {
+	return "discord"
+}
+
+// Capabilities describes the Discord sink
+func (b *Bot) Capabilities() notifier.Capabilities {
+	return notifier.Capabilities{
+		Description:    fmt.Sprintf("Discord bot posting embeds to channel %s", b.current().channelID),
+		RichFormatting: true,
+		Images:         true,
+		DirectMessages: b.current().opts.DMEnabled,
+	}
+}
+
+// Send implements notifier.Notifier
+func (b *Bot) Send(ctx context.Context, event events.Event) error {
+	return b.SendNotification(ctx, event)
+}
 
-	embed := b.createEmbed(notification)
+// SendNotification sends a formatted notification to the Discord channel,
+// retrying transient failures and rate limits according to the bot's policy.
+// When DMs are enabled the requester is messaged first; if their DMs are
+// closed the channel gets the personalised embed instead of the regular one.
+// Redeliveries only post to the channels, the requester was already messaged,
+// and when limited to targets only to the channels that failed before.
+// If some channels fail the error is a notifier.TargetError naming them.
+func (b *Bot) SendNotification(ctx context.Context, event events.Event) error {
+	log.Printf("[DEBUG] [DISCORD] Preparing to send notification - Type: %s, Event: %s", event.Type, event.Kind)
+
+	embed := b.createEmbed(event)
 	log.Printf("[DEBUG] [DISCORD] Created embed with %d fields", len(embed.Fields))
 
-	_, err := b.session.ChannelMessageSendEmbed(b.channelID, embed)
-	if err != nil {
-		log.Printf("[ERROR] [DISCORD] Failed to send message: %v", err)
-		return fmt.Errorf("error sending message to Discord: %v", err)
+	if notifier.IsRedelivery(ctx) {
+		log.Printf("[DEBUG] [DISCORD] Redelivery of %s, not messaging the requester again", event.Kind)
+	} else if userID := b.dmRecipient(event); userID != "" {
+		if err := b.sendDM(ctx, userID, event); err != nil {
+			if isDMClosed(err) {
+				log.Printf("[DEBUG] [DISCORD] DMs closed for user %s, falling back to the channel post", userID)
+				embed = b.createDMEmbed(event)
+			} else {
+				log.Printf("[WARN] [DISCORD] Failed to DM user %s: %v", userID, err)
+			}
+		}
 	}
 
-	log.Printf("[DEBUG] [DISCORD] Message sent successfully to channel %s", b.channelID)
-	log.Printf("Successfully sent notification to Discord channel %s", b.channelID)
-	return nil
+	channels := notifier.Targets(ctx)
+	if len(channels) > 0 {
+		log.Printf("[DEBUG] [DISCORD] Redelivering %s to the failed channel(s): %s", event.Kind, strings.Join(channels, ", "))
+	} else {
+		channels = b.resolveChannels(event)
+		log.Printf("[DEBUG] [DISCORD] Routing %s to %d channel(s): %s", event.Kind, len(channels), strings.Join(channels, ", "))
+	}
+
+	var failed []string
+	var lastErr error
+	for _, channelID := range channels {
+		if err := b.post(ctx, channelID, embed, event); err != nil {
+			log.Printf("[ERROR] [DISCORD] Failed to send message to channel %s: %v", channelID, err)
+			failed = append(failed, channelID)
+			lastErr = err
+			continue
+		}
+		log.Printf("[DEBUG] [DISCORD] Message sent successfully to channel %s", channelID)
+		log.Printf("Successfully sent notification to Discord channel %s", channelID)
+	}
+
+	if lastErr == nil {
+		return nil
+	}
+	// The failed channels go into the dead letter so a redrive skips the
+	// channels that already have the message
+	return &notifier.TargetError{
+		Targets: failed,
+		Err:     fmt.Errorf("error sending message to Discord channel(s) %s: %w", strings.Join(failed, ", "), lastErr),
+	}
+}
+
+// channelMessage wraps the embed in the channel post, with @mentions and
+// approval buttons
+func (b *Bot) channelMessage(embed *discordgo.MessageEmbed, event events.Event) *discordgo.MessageSend {
+	message := withMentions(&discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}, b.mentionTargets(event))
+	message.Components = b.approvalButtons(event)
+	return message
+}
+
+// resolveChannels returns the target channels for a notification
+func (b *Bot) resolveChannels(event events.Event) []string {
+	current := b.current()
+	if channels := current.opts.Routes.Resolve(event); len(channels) > 0 {
+		return channels
+	}
+	return []string{current.channelID}
 }
 
-// createEmbed creates a Discord embed from the notification
-func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEmbed {
+// send posts a message to a channel with retries and returns the created message
+func (b *Bot) send(ctx context.Context, channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	var message *discordgo.Message
+	err := retry.Do(ctx, b.current().policy, func(attempt int) error {
+		log.Printf("[DEBUG] [DISCORD] Sending message to channel %s (attempt %d)", channelID, attempt)
+		var err error
+		message, err = b.session.ChannelMessageSendComplex(channelID, data)
+		return classifyError(err)
+	})
+	return message, err
+}
+
+// createEmbed creates a Discord embed from the notification, using the
+// event's template when one is configured
+func (b *Bot) createEmbed(event events.Event) *discordgo.MessageEmbed {
 	log.Println("[DEBUG] [DISCORD] Creating Discord embed...")
 
+	if embed := b.templateEmbed(event); embed != nil {
+		return embed
+	}
+
 	embed := &discordgo.MessageEmbed{
-		Title:       notification.Subject,
-		Description: notification.Message,
-		Color:       b.getColorForEvent(notification.Event),
+		Title:       event.Title,
+		Description: event.Body,
+		Color:       b.getColorForEvent(event.Kind),
 		Timestamp:   time.Now().Format(time.RFC3339),
 		Fields:      []*discordgo.MessageEmbedField{},
 	}
@@ -113,45 +302,45 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 	log.Printf("[DEBUG] [DISCORD] Embed base created - Title: %s, Color: %d", embed.Title, embed.Color)
 
 	// Add thumbnail only if image URL is provided and not empty
-	if notification.Image != "" {
-		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: notification.Image}
-		log.Printf("[DEBUG] [DISCORD] Added thumbnail: %s", notification.Image)
+	if event.Image() != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: event.Image()}
+		log.Printf("[DEBUG] [DISCORD] Added thumbnail: %s", event.Image())
 	}
 
 	// Add notification type and event info
-	if notification.NotificationType != "" {
+	if event.Type != "" {
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 			Name:   "📋 Type",
-			Value:  notification.NotificationType,
+			Value:  event.Type,
 			Inline: true,
 		})
-		log.Printf("[DEBUG] [DISCORD] Added notification type field: %s", notification.NotificationType)
+		log.Printf("[DEBUG] [DISCORD] Added notification type field: %s", event.Type)
 	}
 
-	if notification.Event != "" {
+	if event.Kind != "" {
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 			Name:   "🎬 Event",
-			Value:  notification.Event,
+			Value:  event.Kind,
 			Inline: true,
 		})
-		log.Printf("[DEBUG] [DISCORD] Added event field: %s", notification.Event)
+		log.Printf("[DEBUG] [DISCORD] Added event field: %s", event.Kind)
 	}
 
 	// Add media information
-	if notification.Media.MediaType != "" {
+	if event.Media.Type != "" {
 		log.Println("[DEBUG] [DISCORD] Processing media information...")
 		mediaInfo := []string{}
-		if notification.Media.MediaType != "" {
-			mediaInfo = append(mediaInfo, fmt.Sprintf("Type: %s", notification.Media.MediaType))
+		if event.Media.Type != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("Type: %s", event.Media.Type))
 		}
-		if notification.Media.Status != "" {
-			mediaInfo = append(mediaInfo, fmt.Sprintf("Status: %s", notification.Media.Status))
+		if event.Media.Status != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("Status: %s", event.Media.Status))
 		}
-		if notification.Media.Status4k != "" {
-			mediaInfo = append(mediaInfo, fmt.Sprintf("4K Status: %s", notification.Media.Status4k))
+		if event.Media.Status4k != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("4K Status: %s", event.Media.Status4k))
 		}
-		if notification.Media.TmdbId != "" {
-			mediaInfo = append(mediaInfo, fmt.Sprintf("TMDB: %s", notification.Media.TmdbId))
+		if event.Media.TmdbID != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("TMDB: %s", event.Media.TmdbID))
 		}
 
 		if len(mediaInfo) > 0 {
@@ -165,16 +354,16 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 	}
 
 	// Add request information
-	if notification.Request.RequestID != "" {
+	if event.RequestID != "" {
 		log.Println("[DEBUG] [DISCORD] Processing request information...")
 		requestInfo := []string{
-			fmt.Sprintf("ID: %s", notification.Request.RequestID),
+			fmt.Sprintf("ID: %s", event.RequestID),
 		}
-		if notification.Request.RequestedByUsername != "" {
-			requestInfo = append(requestInfo, fmt.Sprintf("Requested by: %s", notification.Request.RequestedByUsername))
+		if event.Requester().Name != "" {
+			requestInfo = append(requestInfo, fmt.Sprintf("Requested by: %s", event.Requester().Name))
 		}
-		if notification.Request.RequestedByEmail != "" {
-			requestInfo = append(requestInfo, fmt.Sprintf("Email: %s", notification.Request.RequestedByEmail))
+		if event.Requester().Email != "" {
+			requestInfo = append(requestInfo, fmt.Sprintf("Email: %s", event.Requester().Email))
 		}
 
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
@@ -182,23 +371,23 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 			Value:  strings.Join(requestInfo, "\n"),
 			Inline: false,
 		})
-		log.Printf("[DEBUG] [DISCORD] Added request info field for request ID: %s", notification.Request.RequestID)
+		log.Printf("[DEBUG] [DISCORD] Added request info field for request ID: %s", event.RequestID)
 	}
 
 	// Add issue information
-	if notification.Issue.IssueID != "" {
+	if event.Issue.ID != "" {
 		log.Println("[DEBUG] [DISCORD] Processing issue information...")
 		issueInfo := []string{
-			fmt.Sprintf("ID: %s", notification.Issue.IssueID),
+			fmt.Sprintf("ID: %s", event.Issue.ID),
 		}
-		if notification.Issue.IssueType != "" {
-			issueInfo = append(issueInfo, fmt.Sprintf("Type: %s", notification.Issue.IssueType))
+		if event.Issue.Type != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Type: %s", event.Issue.Type))
 		}
-		if notification.Issue.IssueStatus != "" {
-			issueInfo = append(issueInfo, fmt.Sprintf("Status: %s", notification.Issue.IssueStatus))
+		if event.Issue.Status != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Status: %s", event.Issue.Status))
 		}
-		if notification.Issue.ReportedByUsername != "" {
-			issueInfo = append(issueInfo, fmt.Sprintf("Reported by: %s", notification.Issue.ReportedByUsername))
+		if event.Reporter().Name != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Reported by: %s", event.Reporter().Name))
 		}
 
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
@@ -206,17 +395,17 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 			Value:  strings.Join(issueInfo, "\n"),
 			Inline: false,
 		})
-		log.Printf("[DEBUG] [DISCORD] Added issue info field for issue ID: %s", notification.Issue.IssueID)
+		log.Printf("[DEBUG] [DISCORD] Added issue info field for issue ID: %s", event.Issue.ID)
 	}
 
 	// Add comment information
-	if notification.Comment.CommentMessage != "" {
+	if event.Comment != "" {
 		log.Println("[DEBUG] [DISCORD] Processing comment information...")
 		commentInfo := []string{
-			fmt.Sprintf("Message: %s", notification.Comment.CommentMessage),
+			fmt.Sprintf("Message: %s", event.Comment),
 		}
-		if notification.Comment.CommentedByUsername != "" {
-			commentInfo = append(commentInfo, fmt.Sprintf("By: %s", notification.Comment.CommentedByUsername))
+		if event.Commenter().Name != "" {
+			commentInfo = append(commentInfo, fmt.Sprintf("By: %s", event.Commenter().Name))
 		}
 
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
@@ -224,7 +413,19 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 			Value:  strings.Join(commentInfo, "\n"),
 			Inline: false,
 		})
-		log.Printf("[DEBUG] [DISCORD] Added comment field from user: %s", notification.Comment.CommentedByUsername)
+		log.Printf("[DEBUG] [DISCORD] Added comment field from user: %s", event.Commenter().Name)
+	}
+
+	// Add extra details, e.g. the device of a Jellyfin playback event
+	for _, detail := range event.Attributes {
+		if len(embed.Fields) >= maxFields {
+			break
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   truncate(detail.Name, maxFieldNameLength),
+			Value:  truncate(detail.Value, maxFieldValueLength),
+			Inline: true,
+		})
 	}
 
 	log.Printf("[DEBUG] [DISCORD] Embed creation completed with %d total fields", len(embed.Fields))
@@ -233,22 +434,50 @@ func (b *Bot) createEmbed(notification models.Notification) *discordgo.MessageEm
 
 // getColorForEvent returns an appropriate color for the notification event
 func (b *Bot) getColorForEvent(event string) int {
-	switch strings.ToLower(event) {
-	case "media.available":
-		return 0x00FF00 // Green
-	case "media.requested":
-		return 0x0099FF // Blue
-	case "media.approved":
-		return 0x00FF99 // Teal
-	case "media.declined":
-		return 0xFF0000 // Red
-	case "issue.created":
-		return 0xFF6600 // Orange
-	case "issue.resolved":
-		return 0x00FF00 // Green
-	case "comment.created":
-		return 0x9900FF // Purple
+	return notifier.ColorForEvent(event)
+}
+
+// classifyError marks Discord API errors as permanent or retryable.
+// Rate limits are retried after the delay Discord asks for; configuration
+// problems such as an unknown channel or missing permissions are permanent.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var rateLimit *discordgo.RateLimitError
+	if errors.As(err, &rateLimit) {
+		log.Printf("[WARN] [DISCORD] Rate limited, retry after %s", rateLimit.RetryAfter)
+		return retry.After(err, rateLimit.RetryAfter)
+	}
+
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		// Network errors and other failures without a response are transient
+		return err
+	}
+
+	if restErr.Message != nil {
+		switch restErr.Message.Code {
+		case discordgo.ErrCodeUnknownChannel,
+			discordgo.ErrCodeUnknownUser,
+			discordgo.ErrCodeUnknownMessage,
+			discordgo.ErrCodeMissingAccess,
+			discordgo.ErrCodeMissingPermissions,
+			discordgo.ErrCodeCannotSendMessagesToThisUser:
+			return retry.Permanent(err)
+		}
+	}
+
+	status := restErr.Response.StatusCode
+	switch {
+	case status == http.StatusTooManyRequests:
+		return retry.After(err, retry.RetryAfter(restErr.Response.Header, time.Second))
+	case status >= 500:
+		return err
+	case status >= 400:
+		return retry.Permanent(err)
 	default:
-		return 0x999999 // Gray
+		return err
 	}
 }
@@ -0,0 +1,370 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/activity"
+)
+
+// commandName is the slash command the bot registers
+const commandName = "jellynotifier"
+
+// Limits of the recent subcommand
+const (
+	defaultRecentCount = 10
+	maxRecentCount     = 25
+)
+
+// Controller answers the bot's slash commands from the service's delivery state
+type Controller interface {
+	// Status reports uptime, queue depth and delivery counters
+	Status() activity.Status
+	// Recent returns recent delivery outcomes for events matching a pattern
+	Recent(pattern string, count int) []activity.Record
+	// Mute silences events matching a pattern for d; d <= 0 lifts the mute
+	Mute(pattern string, d time.Duration) (time.Time, error)
+}
+
+// commandScope is where the slash command is registered
+type commandScope struct {
+	enabled bool
+	guildID string
+}
+
+// SetController sets what answers the slash commands. Until it is set the
+// bot replies that the service is still starting.
+func (b *Bot) SetController(controller Controller) {
+	b.commandsMu.Lock()
+	defer b.commandsMu.Unlock()
+	b.controller = controller
+}
+
+// onReady records the application ID and registers the slash command
+func (b *Bot) onReady(_ *discordgo.Session, ready *discordgo.Ready) {
+	b.commandsMu.Lock()
+	b.appID = ready.User.ID
+	b.commandsMu.Unlock()
+	b.syncCommands()
+}
+
+// syncCommands registers or removes the slash command when its settings
+// changed. Nothing happens before the first Ready event; onReady syncs then.
+func (b *Bot) syncCommands() {
+	b.commandsMu.Lock()
+	defer b.commandsMu.Unlock()
+	if b.appID == "" {
+		return
+	}
+
+	opts := b.current().opts
+	want := commandScope{enabled: opts.Commands, guildID: opts.CommandsGuildID}
+	if b.commandsSynced && want == b.registered {
+		return
+	}
+	// Leave commands registered by an earlier run alone until they are enabled
+	if !b.commandsSynced && !want.enabled {
+		b.commandsSynced = true
+		b.registered = want
+		return
+	}
+
+	if b.registered.enabled && (b.registered.guildID != want.guildID || !want.enabled) {
+		log.Printf("[DEBUG] [DISCORD] Removing slash command (guild: %q)", b.registered.guildID)
+		if _, err := b.session.ApplicationCommandBulkOverwrite(b.appID, b.registered.guildID, []*discordgo.ApplicationCommand{}); err != nil {
+			log.Printf("[ERROR] [DISCORD] Failed to remove slash command: %v", err)
+		}
+	}
+	if want.enabled {
+		log.Printf("[DEBUG] [DISCORD] Registering /%s (guild: %q)", commandName, want.guildID)
+		if _, err := b.session.ApplicationCommandBulkOverwrite(b.appID, want.guildID, []*discordgo.ApplicationCommand{command()}); err != nil {
+			log.Printf("[ERROR] [DISCORD] Failed to register slash command: %v", err)
+			return
+		}
+		log.Printf("Registered Discord slash command /%s", commandName)
+	}
+	b.commandsSynced = true
+	b.registered = want
+}
+
+// command describes /jellynotifier and its subcommands
+func command() *discordgo.ApplicationCommand {
+	minCount := 1.0
+	dmPermission := false
+	return &discordgo.ApplicationCommand{
+		Name:         commandName,
+		Description:  "Query and control JellyNotifier",
+		DMPermission: &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "status",
+				Description: "Show uptime, queue depth and delivery counters",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "recent",
+				Description: "Show recent notifications",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "event",
+						Description: "Event or pattern, e.g. media.available or issue.*",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "count",
+						Description: fmt.Sprintf("How many to show (default %d)", defaultRecentCount),
+						MinValue:    &minCount,
+						MaxValue:    maxRecentCount,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "mute",
+				Description: "Silence an event type for a while",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "event",
+						Description: "Event or pattern, e.g. media.pending or issue.*",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "duration",
+						Description: "How long, e.g. 30m, 2h or 1d; 0 unmutes",
+						Required:    true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// onInteraction answers /jellynotifier and button clicks. Command replies
+// are only visible to the user who ran the command.
+func (b *Bot) onInteraction(_ *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if interaction.Type == discordgo.InteractionMessageComponent {
+		b.onButton(interaction)
+		return
+	}
+	if interaction.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	data := interaction.ApplicationCommandData()
+	if data.Name != commandName || len(data.Options) == 0 {
+		return
+	}
+
+	opts := b.current().opts
+	b.commandsMu.Lock()
+	controller := b.controller
+	b.commandsMu.Unlock()
+
+	subcommand := data.Options[0]
+	log.Printf("[DEBUG] [DISCORD] /%s %s from user %s", commandName, subcommand.Name, interactionUser(interaction.Interaction))
+
+	var reply *discordgo.MessageEmbed
+	switch {
+	case !opts.Commands:
+		reply = notice("Slash commands are disabled.")
+	case !authorized(interaction.Interaction, opts):
+		log.Printf("[WARN] [DISCORD] Denied /%s %s to user %s", commandName, subcommand.Name, interactionUser(interaction.Interaction))
+		reply = notice("You are not allowed to use this command.")
+	case controller == nil:
+		reply = notice("JellyNotifier is still starting, try again shortly.")
+	default:
+		reply = runCommand(controller, subcommand)
+	}
+
+	b.reply(interaction.Interaction, reply)
+}
+
+// authorized checks the invoking member against the command roles and,
+// when set, the command guild
+func authorized(interaction *discordgo.Interaction, opts Options) bool {
+	if opts.CommandsGuildID != "" && interaction.GuildID != opts.CommandsGuildID {
+		return false
+	}
+	return memberAllowed(interaction, opts.CommandRoles)
+}
+
+// memberAllowed reports whether the invoking member holds one of roles, or
+// is an administrator when no roles are set. DMs are always refused.
+func memberAllowed(interaction *discordgo.Interaction, roles []string) bool {
+	if interaction.Member == nil {
+		return false
+	}
+	if len(roles) == 0 {
+		return interaction.Member.Permissions&discordgo.PermissionAdministrator != 0
+	}
+	for _, role := range interaction.Member.Roles {
+		if containsFold(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand executes a subcommand against the controller
+func runCommand(controller Controller, subcommand *discordgo.ApplicationCommandInteractionDataOption) *discordgo.MessageEmbed {
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, option := range subcommand.Options {
+		options[option.Name] = option
+	}
+
+	switch subcommand.Name {
+	case "status":
+		return statusEmbed(controller.Status())
+
+	case "recent":
+		pattern := ""
+		if option, ok := options["event"]; ok {
+			pattern = strings.TrimSpace(option.StringValue())
+		}
+		count := defaultRecentCount
+		if option, ok := options["count"]; ok {
+			count = int(option.IntValue())
+		}
+		if count < 1 || count > maxRecentCount {
+			count = defaultRecentCount
+		}
+		return recentEmbed(pattern, controller.Recent(pattern, count))
+
+	case "mute":
+		event := options["event"].StringValue()
+		d, err := parseMuteDuration(options["duration"].StringValue())
+		if err == nil {
+			var until time.Time
+			if until, err = controller.Mute(event, d); err == nil {
+				return muteNotice(event, until)
+			}
+		}
+		return notice(fmt.Sprintf("Could not mute `%s`: %v.", event, err))
+
+	default:
+		return notice(fmt.Sprintf("Unknown subcommand %q.", subcommand.Name))
+	}
+}
+
+// statusEmbed formats the service status
+func statusEmbed(status activity.Status) *discordgo.MessageEmbed {
+	muted := make([]string, 0, len(status.Muted))
+	for pattern, until := range status.Muted {
+		muted = append(muted, fmt.Sprintf("`%s` until <t:%d:R>", pattern, until.Unix()))
+	}
+	sort.Strings(muted)
+	sinks := strings.Join(status.Sinks, ", ")
+	if sinks == "" {
+		sinks = "none"
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "📊 JellyNotifier status",
+		Color: 0x0099FF,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Uptime", Value: time.Since(status.Started).Round(time.Second).String(), Inline: true},
+			{Name: "Queue", Value: fmt.Sprintf("%d / %d", status.QueueDepth, status.QueueCapacity), Inline: true},
+			{Name: "Sinks", Value: sinks, Inline: true},
+			{Name: "Delivered", Value: strconv.Itoa(status.Delivered), Inline: true},
+			{Name: "Failed", Value: strconv.Itoa(status.Failed), Inline: true},
+			{Name: "Dead letters", Value: strconv.Itoa(status.DeadLetters), Inline: true},
+			{Name: "Last delivery", Value: relativeTime(status.LastDelivery), Inline: true},
+			{Name: "Last failure", Value: relativeTime(status.LastFailure), Inline: true},
+			{Name: "Muted", Value: truncate(defaultText(strings.Join(muted, "\n"), "nothing"), maxFieldValueLength)},
+		},
+	}
+}
+
+// recentEmbed lists recent delivery outcomes, newest first
+func recentEmbed(pattern string, records []activity.Record) *discordgo.MessageEmbed {
+	title := "🕒 Recent notifications"
+	if pattern != "" {
+		title += " matching " + pattern
+	}
+
+	lines := make([]string, 0, len(records))
+	for _, record := range records {
+		icon, detail := "✅", strings.Join(record.Sinks, ", ")
+		switch {
+		case record.Muted:
+			icon, detail = "🔇", "muted"
+		case record.Error != "":
+			icon, detail = "❌", truncate(record.Error, 120)
+		}
+		lines = append(lines, fmt.Sprintf("<t:%d:t> %s `%s` %s (%s)", record.At.Unix(), icon, record.Event, record.Subject, detail))
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: truncate(defaultText(strings.Join(lines, "\n"), "No notifications yet."), maxDescriptionLength),
+		Color:       0x0099FF,
+	}
+}
+
+// muteNotice confirms a mute, or its removal when until is zero
+func muteNotice(event string, until time.Time) *discordgo.MessageEmbed {
+	if until.IsZero() {
+		return notice(fmt.Sprintf("🔊 `%s` notifications are no longer muted.", event))
+	}
+	return notice(fmt.Sprintf("🔇 `%s` notifications are muted until <t:%d:f>.", event, until.Unix()))
+}
+
+// parseMuteDuration accepts Go durations plus whole days ("1d"); "0" and
+// "off" unmute
+func parseMuteDuration(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "off" || value == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q, use e.g. 30m, 2h or 1d", value)
+	}
+	return d, nil
+}
+
+// notice is a plain reply
+func notice(text string) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{Description: text, Color: 0x999999}
+}
+
+// relativeTime formats t as a Discord relative timestamp
+func relativeTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("<t:%d:R>", t.Unix())
+}
+
+// defaultText returns fallback when s is empty
+func defaultText(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// interactionUser returns the ID of the user who triggered an interaction
+func interactionUser(interaction *discordgo.Interaction) string {
+	switch {
+	case interaction.Member != nil && interaction.Member.User != nil:
+		return interaction.Member.User.ID
+	case interaction.User != nil:
+		return interaction.User.ID
+	default:
+		return "unknown"
+	}
+}
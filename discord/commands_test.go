@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberAllowed(t *testing.T) {
+	member := func(permissions int64, roles ...string) *discordgo.Interaction {
+		return &discordgo.Interaction{Member: &discordgo.Member{Permissions: permissions, Roles: roles}}
+	}
+
+	tests := []struct {
+		name        string
+		interaction *discordgo.Interaction
+		roles       []string
+		want        bool
+	}{
+		{"direct message", &discordgo.Interaction{User: &discordgo.User{ID: "1"}}, nil, false},
+		{"direct message with roles", &discordgo.Interaction{User: &discordgo.User{ID: "1"}}, []string{"100"}, false},
+		{"administrator without roles", member(discordgo.PermissionAdministrator), nil, true},
+		{"member without roles", member(discordgo.PermissionSendMessages), nil, false},
+		{"member with role", member(0, "200", "100"), []string{"100"}, true},
+		{"member without role", member(0, "200"), []string{"100"}, false},
+		{"administrator without role", member(discordgo.PermissionAdministrator, "200"), []string{"100"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := memberAllowed(tt.interaction, tt.roles); got != tt.want {
+				t.Errorf("memberAllowed = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+)
+
+// dmRecipient returns the Discord user ID to DM for this notification, or ""
+func (b *Bot) dmRecipient(event events.Event) string {
+	opts := b.current().opts
+	if !opts.DMEnabled {
+		return ""
+	}
+	userID := event.Requester().DiscordID
+	if !isSnowflake(userID) {
+		return ""
+	}
+	if !containsFold(opts.DMEvents, event.Kind) {
+		return ""
+	}
+	for _, optedOut := range opts.DMOptOut {
+		if optedOut == userID {
+			log.Printf("[DEBUG] [DISCORD] User %s opted out of DMs, skipping", userID)
+			return ""
+		}
+	}
+	return userID
+}
+
+// sendDM opens (or reuses) a DM channel with the user and sends the personalised embed
+func (b *Bot) sendDM(ctx context.Context, userID string, event events.Event) error {
+	channelID, err := b.dmChannel(userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.send(ctx, channelID, b.dmMessage(event)); err != nil {
+		return fmt.Errorf("error sending DM to user %s: %w", userID, err)
+	}
+	log.Printf("[DEBUG] [DISCORD] Sent DM to user %s", userID)
+	return nil
+}
+
+// dmMessage builds the personalised DM
+func (b *Bot) dmMessage(event events.Event) *discordgo.MessageSend {
+	return withMentions(&discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{b.createDMEmbed(event)}}, nil)
+}
+
+// dmChannel returns the DM channel ID for a user, creating it on first use
+func (b *Bot) dmChannel(userID string) (string, error) {
+	b.dmMu.Lock()
+	defer b.dmMu.Unlock()
+
+	if channelID, ok := b.dmChannels[userID]; ok {
+		return channelID, nil
+	}
+
+	log.Printf("[DEBUG] [DISCORD] Opening DM channel with user %s", userID)
+	channel, err := b.session.UserChannelCreate(userID)
+	if err != nil {
+		return "", fmt.Errorf("error opening DM channel with user %s: %w", userID, err)
+	}
+	b.dmChannels[userID] = channel.ID
+	return channel.ID, nil
+}
+
+// createDMEmbed builds the embed addressed to the requester
+func (b *Bot) createDMEmbed(event events.Event) *discordgo.MessageEmbed {
+	log.Println("[DEBUG] [DISCORD] Creating personalised DM embed...")
+
+	name := event.Requester().Name
+	if name == "" {
+		name = "there"
+	}
+	title := event.Title
+
+	var intro string
+	switch strings.ToLower(event.Kind) {
+	case "media.available":
+		intro = fmt.Sprintf("Hi %s, your request is now available to watch! 🍿", name)
+		title = "🎉 " + title
+	case "media.approved":
+		intro = fmt.Sprintf("Hi %s, your request has been approved and will be available soon.", name)
+		title = "✅ " + title
+	case "media.declined":
+		intro = fmt.Sprintf("Hi %s, unfortunately your request was declined.", name)
+		title = "❌ " + title
+	default:
+		intro = fmt.Sprintf("Hi %s, there is an update on your request.", name)
+	}
+
+	description := intro
+	if event.Body != "" {
+		description += "\n\n" + event.Body
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       b.getColorForEvent(event.Kind),
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	if event.Image() != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: event.Image()}
+	}
+	return embed
+}
+
+// isDMClosed reports whether Discord refused the DM because of the user's privacy settings
+func isDMClosed(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeCannotSendMessagesToThisUser
+	}
+	return false
+}
+
+// containsFold reports whether list contains value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
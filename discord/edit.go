@@ -0,0 +1,146 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+	"jellynotifier/retry"
+)
+
+// post delivers the channel message for a notification. Media events of a
+// request already posted in the channel edit that message and extend its
+// status timeline; everything else, and every event when editing is
+// disabled, posts a new message. Issue and comment events go to the
+// issue's thread.
+func (b *Bot) post(ctx context.Context, channelID string, embed *discordgo.MessageEmbed, event events.Event) error {
+	if key := b.threadKey(event, channelID); key != "" {
+		return b.postIssue(ctx, channelID, key, embed, event)
+	}
+
+	key := b.trackingKey(event, channelID)
+	if key == "" {
+		_, err := b.send(ctx, channelID, b.channelMessage(embed, event))
+		return err
+	}
+
+	// Serialise posts for the request so two workers cannot both post a
+	// first message; other requests are not held up by its retries
+	defer b.trackLocks.lock(key)()
+
+	tracked, exists := b.tracker.message(key)
+	timeline := append(append([]TimelineEntry(nil), tracked.Timeline...), TimelineEntry{Event: event.Kind, At: time.Now()})
+	message := b.channelMessage(withTimeline(embed, timeline), event)
+
+	if exists && !containsFold(b.current().opts.NewMessageEvents, event.Kind) {
+		err := b.edit(ctx, tracked.ChannelID, tracked.MessageID, message)
+		if err == nil {
+			log.Printf("[DEBUG] [DISCORD] Edited message %s for %s", tracked.MessageID, key)
+			tracked.Timeline = timeline
+			tracked.UpdatedAt = time.Now()
+			b.tracker.track(key, tracked)
+			return nil
+		}
+		if !isUnknownMessage(err) {
+			return err
+		}
+		log.Printf("[DEBUG] [DISCORD] Message %s for %s was deleted, posting a new one", tracked.MessageID, key)
+	}
+
+	sent, err := b.send(ctx, channelID, message)
+	if err != nil {
+		return err
+	}
+	b.tracker.track(key, TrackedMessage{
+		ChannelID: channelID,
+		MessageID: sent.ID,
+		Timeline:  timeline,
+		UpdatedAt: time.Now(),
+	})
+	log.Printf("[DEBUG] [DISCORD] Tracking message %s for %s", sent.ID, key)
+	return nil
+}
+
+// trackingKey returns the tracker key for the notification in a channel,
+// or "" when the message should not be tracked
+func (b *Bot) trackingKey(event events.Event, channelID string) string {
+	if b.tracker == nil || !b.current().opts.EditMessages {
+		return ""
+	}
+	key := requestKey(event)
+	if key == "" {
+		return ""
+	}
+	return channelID + "/" + key
+}
+
+// edit replaces the embeds of a posted message with retries
+func (b *Bot) edit(ctx context.Context, channelID, messageID string, data *discordgo.MessageSend) error {
+	edit := discordgo.NewMessageEdit(channelID, messageID)
+	edit.Embeds = data.Embeds
+	// An empty list removes buttons left over from an earlier status
+	edit.Components = data.Components
+	if edit.Components == nil {
+		edit.Components = []discordgo.MessageComponent{}
+	}
+	edit.AllowedMentions = data.AllowedMentions
+
+	err := retry.Do(ctx, b.current().policy, func(attempt int) error {
+		log.Printf("[DEBUG] [DISCORD] Editing message %s in channel %s (attempt %d)", messageID, channelID, attempt)
+		_, err := b.session.ChannelMessageEditComplex(edit)
+		return classifyError(err)
+	})
+	if err != nil {
+		return fmt.Errorf("error editing Discord message %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// withTimeline returns a copy of the embed with the status timeline appended
+func withTimeline(embed *discordgo.MessageEmbed, timeline []TimelineEntry) *discordgo.MessageEmbed {
+	if len(timeline) > maxTimelineEntries {
+		timeline = timeline[len(timeline)-maxTimelineEntries:]
+	}
+	lines := make([]string, 0, len(timeline))
+	for _, entry := range timeline {
+		lines = append(lines, fmt.Sprintf("<t:%d:f> %s", entry.At.Unix(), statusLabel(entry.Event)))
+	}
+
+	fields := embed.Fields
+	if len(fields) >= maxFields {
+		fields = fields[:maxFields-1]
+	}
+	copied := *embed
+	copied.Fields = append(append([]*discordgo.MessageEmbedField(nil), fields...), &discordgo.MessageEmbedField{
+		Name:  "🕒 Timeline",
+		Value: truncate(strings.Join(lines, "\n"), maxFieldValueLength),
+	})
+	return &copied
+}
+
+// statusLabel turns "media.auto_approved" into "Auto approved"
+func statusLabel(event string) string {
+	label := event
+	if i := strings.LastIndex(label, "."); i >= 0 {
+		label = label[i+1:]
+	}
+	label = strings.ReplaceAll(label, "_", " ")
+	if label == "" {
+		return event
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// isUnknownMessage reports whether Discord rejected an edit because the message is gone
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package discord
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+)
+
+// mentionTargets returns the Discord user IDs a channel post should @mention:
+// the requester on media events, the reporter on issue events and the
+// commenter on comment events. Only events listed in MentionEvents qualify.
+func (b *Bot) mentionTargets(event events.Event) []string {
+	if !containsFold(b.current().opts.MentionEvents, event.Kind) {
+		return nil
+	}
+
+	var userID string
+	kind := strings.ToLower(event.Kind)
+	switch {
+	case strings.HasPrefix(kind, "media."):
+		userID = event.Requester().DiscordID
+	case strings.HasPrefix(kind, "issue."):
+		userID = event.Reporter().DiscordID
+	case strings.HasPrefix(kind, "comment."):
+		userID = event.Commenter().DiscordID
+	}
+
+	if !isSnowflake(userID) {
+		if userID != "" {
+			log.Printf("[WARN] [DISCORD] Ignoring invalid Discord user ID %q in payload", userID)
+		}
+		return nil
+	}
+	return []string{userID}
+}
+
+// withMentions adds @mentions for userIDs to a message and restricts the
+// allowed mentions to exactly those users, so nothing in the payload can
+// trigger @everyone, @here or role pings
+func withMentions(data *discordgo.MessageSend, userIDs []string) *discordgo.MessageSend {
+	data.AllowedMentions = &discordgo.MessageAllowedMentions{
+		Parse: []discordgo.AllowedMentionType{},
+		Users: userIDs,
+	}
+	if len(userIDs) == 0 {
+		return data
+	}
+
+	mentions := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		mentions = append(mentions, "<@"+userID+">")
+	}
+	data.Content = strings.Join(mentions, " ")
+	log.Printf("[DEBUG] [DISCORD] Mentioning %d user(s) in channel message", len(userIDs))
+	return data
+}
+
+// isSnowflake reports whether id looks like a Discord snowflake ID
+func isSnowflake(id string) bool {
+	if len(id) < 15 || len(id) > 21 {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package discord
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+	"jellynotifier/retry"
+)
+
+// Rendering is what SendNotification would post for a notification
+type Rendering struct {
+	// Channels the message would be posted to after routing
+	Channels []string               `json:"channels"`
+	Message  *discordgo.MessageSend `json:"message"`
+	// DM is set when the requester would also get a direct message
+	DM *DMRendering `json:"dm,omitempty"`
+}
+
+// DMRendering is the direct message sent to the requester
+type DMRendering struct {
+	UserID  string                 `json:"user_id"`
+	Message *discordgo.MessageSend `json:"message"`
+}
+
+// NewRenderer creates a Bot without a Discord session. It can Render
+// notifications for previews but must not be used to Send them.
+func NewRenderer(channelID string, opts Options) *Bot {
+	bot := &Bot{dmChannels: make(map[string]string)}
+	bot.settings.Store(&settings{channelID: channelID, policy: retry.DefaultPolicy(), opts: opts})
+	return bot
+}
+
+// Render implements notifier.Renderer using the same embed, template,
+// routing and mention logic as SendNotification. The DM-closed fallback
+// depends on Discord's answer and is not reflected.
+func (b *Bot) Render(event events.Event) (interface{}, error) {
+	log.Printf("[DEBUG] [DISCORD] Rendering notification - Event: %s", event.Kind)
+
+	rendering := &Rendering{
+		Channels: b.resolveChannels(event),
+		Message:  b.channelMessage(b.createEmbed(event), event),
+	}
+	if userID := b.dmRecipient(event); userID != "" {
+		rendering.DM = &DMRendering{UserID: userID, Message: b.dmMessage(event)}
+	}
+	return rendering, nil
+}
@@ -0,0 +1,79 @@
+package discord
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+)
+
+// Discord embed limits
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 4096
+	maxFieldNameLength   = 256
+	maxFieldValueLength  = 1024
+	maxFields            = 25
+	maxFooterLength      = 2048
+)
+
+// templateEmbed renders the user template for the notification's event.
+// It returns nil when there is no template or it fails to render, in which
+// case the built-in layout is used.
+func (b *Bot) templateEmbed(event events.Event) *discordgo.MessageEmbed {
+	message, ok, err := b.current().opts.Templates.Render(event)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] [DISCORD] %v, falling back to the built-in layout", err)
+		return nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       truncate(message.Title, maxTitleLength),
+		Description: truncate(message.Description, maxDescriptionLength),
+		Color:       message.Color,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields:      []*discordgo.MessageEmbedField{},
+	}
+	if embed.Color == 0 {
+		embed.Color = b.getColorForEvent(event.Kind)
+	}
+
+	thumbnail := message.Thumbnail
+	if thumbnail == "" {
+		thumbnail = event.Image()
+	}
+	if thumbnail != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: thumbnail}
+	}
+	if message.Footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: truncate(message.Footer, maxFooterLength)}
+	}
+
+	for i, field := range message.Fields {
+		if i == maxFields {
+			log.Printf("[WARN] [DISCORD] Template produced %d fields, keeping the first %d", len(message.Fields), maxFields)
+			break
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   truncate(field.Name, maxFieldNameLength),
+			Value:  truncate(field.Value, maxFieldValueLength),
+			Inline: field.Inline,
+		})
+	}
+
+	log.Printf("[DEBUG] [DISCORD] Rendered template embed for %s with %d fields", event.Kind, len(embed.Fields))
+	return embed
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,163 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"jellynotifier/events"
+	"jellynotifier/retry"
+)
+
+// threadAutoArchive is how long, in minutes, an idle issue thread stays open
+const threadAutoArchive = 10080
+
+// maxThreadNameLength is Discord's limit on thread names
+const maxThreadNameLength = 100
+
+// postIssue delivers an issue or comment notification. issue.created posts
+// in the channel and starts a thread on that message; later notifications
+// for the issue go into the thread, and issue.resolved archives it. Without
+// a thread, or when it was deleted, the notification is posted in the
+// channel as usual.
+func (b *Bot) postIssue(ctx context.Context, channelID, key string, embed *discordgo.MessageEmbed, event events.Event) error {
+	// Serialise posts for the issue so only one of them starts its thread
+	defer b.trackLocks.lock(key)()
+
+	message := b.channelMessage(embed, event)
+
+	if thread, exists := b.tracker.thread(key); exists {
+		err := b.postInThread(ctx, key, thread, message, event)
+		if err == nil {
+			return nil
+		}
+		if !isUnknownChannel(err) {
+			return err
+		}
+		log.Printf("[DEBUG] [DISCORD] Thread %s for %s was deleted, posting in the channel", thread.ThreadID, key)
+		b.tracker.forgetThread(key)
+	}
+
+	sent, err := b.send(ctx, channelID, message)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(event.Kind, "issue.created") {
+		b.startThread(ctx, channelID, sent.ID, key, event)
+	}
+	return nil
+}
+
+// postInThread sends the message into the issue's thread, reopening it if
+// it was archived and archiving it again when the issue is resolved
+func (b *Bot) postInThread(ctx context.Context, key string, thread IssueThread, message *discordgo.MessageSend, event events.Event) error {
+	if thread.Archived {
+		if err := b.archiveThread(ctx, thread.ThreadID, false); err != nil {
+			return err
+		}
+		thread.Archived = false
+	}
+
+	if _, err := b.send(ctx, thread.ThreadID, message); err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] [DISCORD] Posted %s into thread %s for %s", event.Kind, thread.ThreadID, key)
+
+	if strings.EqualFold(event.Kind, "issue.resolved") {
+		// The update is already posted; a failed archive must not resend it
+		if err := b.archiveThread(ctx, thread.ThreadID, true); err != nil {
+			log.Printf("[WARN] [DISCORD] Failed to archive thread %s for %s: %v", thread.ThreadID, key, err)
+		} else {
+			thread.Archived = true
+		}
+	}
+
+	thread.UpdatedAt = time.Now()
+	b.tracker.trackThread(key, thread)
+	return nil
+}
+
+// startThread starts the issue's thread on the posted message. The
+// notification is already delivered, so a failure is only logged and later
+// notifications for the issue go to the channel.
+func (b *Bot) startThread(ctx context.Context, channelID, messageID, key string, event events.Event) {
+	data := &discordgo.ThreadStart{
+		Name:                threadName(event),
+		AutoArchiveDuration: threadAutoArchive,
+	}
+
+	var thread *discordgo.Channel
+	err := retry.Do(ctx, b.current().policy, func(attempt int) error {
+		log.Printf("[DEBUG] [DISCORD] Starting thread on message %s in channel %s (attempt %d)", messageID, channelID, attempt)
+		var err error
+		thread, err = b.session.MessageThreadStartComplex(channelID, messageID, data)
+		return classifyError(err)
+	})
+	if err != nil {
+		log.Printf("[WARN] [DISCORD] Failed to start thread for %s: %v", key, err)
+		return
+	}
+
+	b.tracker.trackThread(key, IssueThread{
+		ChannelID: channelID,
+		ThreadID:  thread.ID,
+		UpdatedAt: time.Now(),
+	})
+	log.Printf("[DEBUG] [DISCORD] Started thread %s for %s", thread.ID, key)
+}
+
+// archiveThread archives or unarchives a thread with retries. The request is
+// built by hand because discordgo.ChannelEdit always sends a position,
+// which threads do not have.
+func (b *Bot) archiveThread(ctx context.Context, threadID string, archived bool) error {
+	endpoint := discordgo.EndpointChannel(threadID)
+	data := struct {
+		Archived bool `json:"archived"`
+	}{archived}
+
+	err := retry.Do(ctx, b.current().policy, func(attempt int) error {
+		log.Printf("[DEBUG] [DISCORD] Setting thread %s archived=%t (attempt %d)", threadID, archived, attempt)
+		_, err := b.session.RequestWithBucketID("PATCH", endpoint, data, endpoint)
+		return classifyError(err)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating Discord thread %s: %w", threadID, err)
+	}
+	return nil
+}
+
+// threadKey returns the tracker key of the issue thread for the
+// notification in a channel, or "" when it does not belong in a thread
+func (b *Bot) threadKey(event events.Event, channelID string) string {
+	if b.tracker == nil || !b.current().opts.IssueThreads {
+		return ""
+	}
+	key := issueKey(event)
+	if key == "" {
+		return ""
+	}
+	return channelID + "/" + key
+}
+
+// threadName names an issue thread after the issue and its subject
+func threadName(event events.Event) string {
+	name := "Issue #" + event.Issue.ID
+	if event.Title != "" {
+		name += ": " + event.Title
+	}
+	return truncate(name, maxThreadNameLength)
+}
+
+// isUnknownChannel reports whether Discord rejected a request because the
+// channel or thread is gone
+func isUnknownChannel(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownChannel
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package discord
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/store"
+)
+
+// MessagesFile is the name of the tracked-message file inside the data directory
+const MessagesFile = "discord_messages.json"
+
+// ThreadsFile is the name of the issue thread file inside the data directory
+const ThreadsFile = "discord_threads.json"
+
+// trackedRetention is how long a tracked message stays editable after its
+// last update; older entries are pruned so the file does not grow forever
+const trackedRetention = 90 * 24 * time.Hour
+
+// maxTimelineEntries caps the status timeline shown on an edited message
+const maxTimelineEntries = 10
+
+// TrackedMessage is a channel post that later notifications for the same
+// request edit in place
+type TrackedMessage struct {
+	ChannelID string          `json:"channel_id"`
+	MessageID string          `json:"message_id"`
+	Timeline  []TimelineEntry `json:"timeline"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// TimelineEntry records one status change of a request
+type TimelineEntry struct {
+	Event string    `json:"event"`
+	At    time.Time `json:"at"`
+}
+
+// IssueThread is the thread started for an issue
+type IssueThread struct {
+	ChannelID string    `json:"channel_id"`
+	ThreadID  string    `json:"thread_id"`
+	Archived  bool      `json:"archived"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Tracker persists the Discord messages posted per request and the threads
+// started per issue so they survive a restart
+type Tracker struct {
+	messages *store.Map[TrackedMessage]
+	threads  *store.Map[IssueThread]
+}
+
+// OpenTracker loads the tracked messages and issue threads from dir
+func OpenTracker(dir string) (*Tracker, error) {
+	messages, err := store.Open[TrackedMessage](filepath.Join(dir, MessagesFile))
+	if err != nil {
+		return nil, err
+	}
+	threads, err := store.Open[IssueThread](filepath.Join(dir, ThreadsFile))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] [DISCORD] Tracking %d request message(s) and %d issue thread(s)", messages.Len(), threads.Len())
+	return &Tracker{messages: messages, threads: threads}, nil
+}
+
+// message returns the message tracked under key
+func (t *Tracker) message(key string) (TrackedMessage, bool) {
+	return t.messages.Get(key)
+}
+
+// track stores the message under key and prunes stale entries
+func (t *Tracker) track(key string, message TrackedMessage) {
+	if err := t.messages.Put(key, message); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to persist tracked message %s: %v", key, err)
+	}
+	cutoff := time.Now().Add(-trackedRetention)
+	if _, err := t.messages.DeleteFunc(func(_ string, m TrackedMessage) bool { return m.UpdatedAt.Before(cutoff) }); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to prune tracked messages: %v", err)
+	}
+}
+
+// thread returns the issue thread stored under key
+func (t *Tracker) thread(key string) (IssueThread, bool) {
+	return t.threads.Get(key)
+}
+
+// trackThread stores the issue thread under key and prunes stale entries
+func (t *Tracker) trackThread(key string, thread IssueThread) {
+	if err := t.threads.Put(key, thread); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to persist issue thread %s: %v", key, err)
+	}
+	cutoff := time.Now().Add(-trackedRetention)
+	if _, err := t.threads.DeleteFunc(func(_ string, th IssueThread) bool { return th.UpdatedAt.Before(cutoff) }); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to prune issue threads: %v", err)
+	}
+}
+
+// forgetThread removes the issue thread stored under key
+func (t *Tracker) forgetThread(key string) {
+	if _, err := t.threads.Delete(key); err != nil {
+		log.Printf("[ERROR] [DISCORD] Failed to remove issue thread %s: %v", key, err)
+	}
+}
+
+// keyedMutex serialises work per key without making other keys wait. Its
+// zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the mutex of one key and how many callers hold or wait for it
+type keyLock struct {
+	sync.Mutex
+	users int
+}
+
+// lock locks key and returns the function that unlocks it
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.users++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// requestKey identifies the request a media notification belongs to: the
+// Overseerr request ID, or the TMDB ID and media type without one. Other
+// events are not tracked.
+func requestKey(event events.Event) string {
+	if !strings.HasPrefix(strings.ToLower(event.Kind), "media.") {
+		return ""
+	}
+	switch {
+	case event.RequestID != "":
+		return "request:" + event.RequestID
+	case event.Media.TmdbID != "" && event.Media.Type != "":
+		return "media:" + strings.ToLower(event.Media.Type) + ":" + event.Media.TmdbID
+	default:
+		return ""
+	}
+}
+
+// issueKey identifies the issue an issue or comment notification belongs
+// to. Other events, and issue events without an ID, have no thread.
+func issueKey(event events.Event) string {
+	kind := strings.ToLower(event.Kind)
+	if event.Issue.ID == "" || !(strings.HasPrefix(kind, "issue.") || strings.HasPrefix(kind, "comment.")) {
+		return ""
+	}
+	return "issue:" + event.Issue.ID
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Adapter converts the webhook requests of one source into events
+type Adapter interface {
+	// Source names the source, e.g. "sonarr"; it is also the last element
+	// of the source's webhook path, /webhook/sonarr
+	Source() string
+	// Detect reports whether a request to the shared /webhook endpoint
+	// came from this source
+	Detect(header http.Header, body []byte) bool
+	// Decode converts a request into events
+	Decode(header http.Header, body []byte) ([]Event, error)
+}
+
+// Adapters is an ordered list of adapters; detection tries them in order
+type Adapters []Adapter
+
+// Get returns the adapter for a source
+func (a Adapters) Get(source string) (Adapter, bool) {
+	for _, adapter := range a {
+		if adapter.Source() == source {
+			return adapter, true
+		}
+	}
+	return nil, false
+}
+
+// Sources returns the names of the sources
+func (a Adapters) Sources() []string {
+	sources := make([]string, 0, len(a))
+	for _, adapter := range a {
+		sources = append(sources, adapter.Source())
+	}
+	return sources
+}
+
+// Decode converts a request of unknown origin with the first adapter that
+// detects it
+func (a Adapters) Decode(header http.Header, body []byte) ([]Event, error) {
+	for _, adapter := range a {
+		if adapter.Detect(header, body) {
+			return adapter.Decode(header, body)
+		}
+	}
+	return nil, fmt.Errorf("payload is not from a known source")
+}
+
+// SnakeCase turns a payload's "SomeEventType" into "some_event_type"
+func SnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FirstNonEmpty returns the first non-empty value
+func FirstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// Package events defines the source-agnostic event that every webhook is
+// converted into. Routing, templates and sinks work on events only; the
+// wire formats of Overseerr, Jellyfin, Sonarr and Radarr stay in their
+// adapters.
+package events
+
+// Actor roles
+const (
+	// RoleRequester asked for the media
+	RoleRequester = "requester"
+	// RoleReporter opened the issue
+	RoleReporter = "reporter"
+	// RoleCommenter wrote the comment
+	RoleCommenter = "commenter"
+	// RoleUser is the user a server event concerns, e.g. who started playback
+	RoleUser = "user"
+)
+
+// Image kinds
+const (
+	ImagePoster   = "poster"
+	ImageBackdrop = "backdrop"
+)
+
+// Event is a normalised notification from any source
+type Event struct {
+	// Source names the adapter that produced the event, e.g. "sonarr"
+	Source string `json:"source"`
+	// Kind is the event name used for routing, templates and colours,
+	// e.g. "media.available" or "download.completed"
+	Kind string `json:"kind"`
+	// Type is the source's own name for the event, e.g. "MEDIA_AVAILABLE"
+	Type   string  `json:"type,omitempty"`
+	Title  string  `json:"title,omitempty"`
+	Body   string  `json:"body,omitempty"`
+	Images []Image `json:"images,omitempty"`
+	Media  Media   `json:"media"`
+	// Actors are the people involved, at most one per role
+	Actors []Actor `json:"actors,omitempty"`
+	// RequestID identifies the Overseerr request the event concerns
+	RequestID string `json:"request_id,omitempty"`
+	Issue     Issue  `json:"issue"`
+	// Comment is the text of an issue comment
+	Comment string `json:"comment,omitempty"`
+	// Attributes are further name/value details every sink lists
+	Attributes []Attribute `json:"attributes,omitempty"`
+}
+
+// Image is a picture of the media
+type Image struct {
+	Kind string `json:"kind,omitempty"`
+	URL  string `json:"url"`
+}
+
+// Media identifies the movie, series or episodes an event concerns
+type Media struct {
+	// Type is "movie" or "tv" when known
+	Type string `json:"type,omitempty"`
+	// Title is the movie or series title
+	Title    string `json:"title,omitempty"`
+	Season   int    `json:"season,omitempty"`
+	Episodes []int  `json:"episodes,omitempty"`
+	TmdbID   string `json:"tmdb_id,omitempty"`
+	TvdbID   string `json:"tvdb_id,omitempty"`
+	ImdbID   string `json:"imdb_id,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Status4k string `json:"status4k,omitempty"`
+}
+
+// Actor is a person involved in an event
+type Actor struct {
+	Role           string `json:"role"`
+	Name           string `json:"name,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Avatar         string `json:"avatar,omitempty"`
+	DiscordID      string `json:"discord_id,omitempty"`
+	TelegramChatID string `json:"telegram_chat_id,omitempty"`
+}
+
+// Issue describes the Overseerr issue an event concerns
+type Issue struct {
+	ID     string `json:"id,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// Attribute is a name/value detail
+type Attribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Image returns the poster URL, or the first image's, or ""
+func (e Event) Image() string {
+	for _, image := range e.Images {
+		if image.Kind == ImagePoster {
+			return image.URL
+		}
+	}
+	if len(e.Images) > 0 {
+		return e.Images[0].URL
+	}
+	return ""
+}
+
+// Actor returns the actor with the given role, or a zero Actor
+func (e Event) Actor(role string) Actor {
+	for _, actor := range e.Actors {
+		if actor.Role == role {
+			return actor
+		}
+	}
+	return Actor{}
+}
+
+// Requester returns who asked for the media
+func (e Event) Requester() Actor {
+	return e.Actor(RoleRequester)
+}
+
+// Reporter returns who opened the issue
+func (e Event) Reporter() Actor {
+	return e.Actor(RoleReporter)
+}
+
+// Commenter returns who wrote the comment
+func (e Event) Commenter() Actor {
+	return e.Actor(RoleCommenter)
+}
+
+// AddActor appends an actor unless it has neither a name nor any contact
+func (e *Event) AddActor(actor Actor) {
+	if actor.Name == "" && actor.Email == "" && actor.DiscordID == "" && actor.TelegramChatID == "" {
+		return
+	}
+	e.Actors = append(e.Actors, actor)
+}
+
+// AddImage appends an image unless its URL is empty
+func (e *Event) AddImage(kind, url string) {
+	if url == "" {
+		return
+	}
+	e.Images = append(e.Images, Image{Kind: kind, URL: url})
+}
+
+// Attr returns the value of the named attribute, or ""
+func (e Event) Attr(name string) string {
+	for _, attribute := range e.Attributes {
+		if attribute.Name == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+// SetAttr appends a non-empty attribute unless one with the same name exists
+func (e *Event) SetAttr(name, value string) {
+	if name == "" || value == "" || e.Attr(name) != "" {
+		return
+	}
+	e.Attributes = append(e.Attributes, Attribute{Name: name, Value: value})
+}
@@ -2,7 +2,11 @@ module jellynotifier
 
 go 1.24.4
 
-require github.com/bwmarrin/discordgo v0.27.1
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/bwmarrin/discordgo v0.27.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/gorilla/websocket v1.4.2 // indirect
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"jellynotifier/deadletter"
+	"jellynotifier/queue"
+)
+
+// ListDeadLetters returns every dead letter as JSON
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters := h.deadLetters.List()
+	log.Printf("[DEBUG] [ADMIN] Listing %d dead letters for %s", len(letters), r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":        len(letters),
+		"dead_letters": letters,
+	})
+}
+
+// GetDeadLetter returns a single dead letter by ID
+func (h *Handler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	letter, ok := h.deadLetters.Get(id)
+	if !ok {
+		log.Printf("[DEBUG] [ADMIN] Dead letter %s not found", id)
+		writeError(w, http.StatusNotFound, "dead letter not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, letter)
+}
+
+// RedriveDeadLetter puts a single dead letter back on the delivery queue
+func (h *Handler) RedriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	letter, ok := h.deadLetters.Get(id)
+	if !ok {
+		log.Printf("[DEBUG] [ADMIN] Dead letter %s not found", id)
+		writeError(w, http.StatusNotFound, "dead letter not found")
+		return
+	}
+
+	newID, err := h.redrive(letter)
+	if err != nil {
+		writeError(w, redriveStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"id":        id,
+		"outbox_id": newID,
+	})
+}
+
+// redriveRequest selects dead letters for a bulk redrive; no IDs means all of them
+type redriveRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// RedriveDeadLetters puts several (or all) dead letters back on the delivery queue.
+// It stops at the first failure so a full queue is not hammered.
+func (h *Handler) RedriveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var req redriveRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[ERROR] [ADMIN] Invalid bulk redrive body: %v", err)
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+
+	var letters []deadletter.Letter
+	if len(req.IDs) == 0 {
+		letters = h.deadLetters.List()
+	} else {
+		for _, id := range req.IDs {
+			letter, ok := h.deadLetters.Get(id)
+			if !ok {
+				writeError(w, http.StatusNotFound, "dead letter not found: "+id)
+				return
+			}
+			letters = append(letters, letter)
+		}
+	}
+
+	log.Printf("[DEBUG] [ADMIN] Bulk redrive of %d dead letters requested by %s", len(letters), r.RemoteAddr)
+	redriven := make([]string, 0, len(letters))
+	for _, letter := range letters {
+		if _, err := h.redrive(letter); err != nil {
+			writeJSON(w, redriveStatus(err), map[string]interface{}{
+				"redriven": redriven,
+				"failed":   letter.ID,
+				"error":    err.Error(),
+			})
+			return
+		}
+		redriven = append(redriven, letter.ID)
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"redriven": redriven,
+	})
+}
+
+// DeleteDeadLetter removes a single dead letter without delivering it
+func (h *Handler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	removed, err := h.deadLetters.Delete(id)
+	if err != nil {
+		log.Printf("[ERROR] [ADMIN] Failed to delete dead letter %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, "dead letter not found")
+		return
+	}
+	log.Printf("[DEBUG] [ADMIN] Deleted dead letter %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeDeadLetters removes every dead letter
+func (h *Handler) PurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.deadLetters.Purge()
+	if err != nil {
+		log.Printf("[ERROR] [ADMIN] Failed to purge dead letters: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("[DEBUG] [ADMIN] Purged %d dead letters for %s", removed, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]int{"purged": removed})
+}
+
+// RenderNotification renders a webhook payload with every enabled sink
+// without sending it. The optional ?source= query parameter names the
+// payload's source instead of detecting it, and ?event= overrides the
+// event, e.g. to preview another event's template.
+func (h *Handler) RenderNotification(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		log.Printf("[ERROR] [ADMIN] Error reading render payload: %v", err)
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	decode := h.adapters.Decode
+	if source := r.URL.Query().Get("source"); source != "" {
+		adapter, ok := h.adapters.Get(source)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "unknown source: "+source)
+			return
+		}
+		decode = adapter.Decode
+	}
+	decoded, err := decode(r.Header, body)
+	if err != nil || len(decoded) == 0 {
+		log.Printf("[ERROR] [ADMIN] Invalid render payload: %v", err)
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	event := decoded[0]
+	if kind := r.URL.Query().Get("event"); kind != "" {
+		event.Kind = kind
+	}
+
+	log.Printf("[DEBUG] [ADMIN] Rendering %s for %s", event.Kind, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"event": event.Kind,
+		"sinks": h.notifiers.Render(event),
+	})
+}
+
+// redrive re-accepts a dead letter for the sink and destinations that
+// failed and removes it from the store once queued
+func (h *Handler) redrive(letter deadletter.Letter) (string, error) {
+	var sinks []string
+	if letter.Sink != "" {
+		sinks = []string{letter.Sink}
+	}
+	entry, err := h.accept(letter.Event, sinks, letter.Targets)
+	if err != nil {
+		log.Printf("[ERROR] [ADMIN] Failed to redrive dead letter %s: %v", letter.ID, err)
+		return "", err
+	}
+	if _, err := h.deadLetters.Delete(letter.ID); err != nil {
+		log.Printf("[ERROR] [ADMIN] Redrove %s but failed to remove it from the dead-letter store: %v", letter.ID, err)
+	}
+	log.Printf("[DEBUG] [ADMIN] Redrove dead letter %s as outbox entry %s", letter.ID, entry.ID)
+	return entry.ID, nil
+}
+
+// redriveStatus maps a redrive failure to an HTTP status
+func redriveStatus(err error) int {
+	if errors.Is(err, queue.ErrFull) || errors.Is(err, queue.ErrClosed) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERROR] [ADMIN] Failed to encode JSON response: %v", err)
+	}
+}
+
+// writeError writes a JSON error body
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
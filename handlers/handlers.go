@@ -1,42 +1,211 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
-
+	"time"
+
+	"jellynotifier/activity"
+	"jellynotifier/arr"
+	"jellynotifier/coalesce"
+	"jellynotifier/deadletter"
+	"jellynotifier/dedup"
+	"jellynotifier/events"
+	"jellynotifier/jellyfin"
 	"jellynotifier/models"
+	"jellynotifier/notifier"
+	"jellynotifier/outbox"
+	"jellynotifier/overseerr"
+	"jellynotifier/queue"
+	"jellynotifier/retry"
 )
 
 // Handler handles incoming webhook notifications
 type Handler struct {
-	discordBot DiscordBot
+	notifiers   *notifier.Registry
+	outbox      *outbox.Outbox
+	deadLetters *deadletter.Store
+	queue       *queue.Queue
+	activity    *activity.Log
+	mutes       *activity.Mutes
+	seen        *dedup.Cache
+	batches     *coalesce.Batcher
+	adapters    events.Adapters
 }
 
-// DiscordBot interface for Discord bot functionality
-type DiscordBot interface {
-	SendNotification(notification models.Notification) error
+// recentSize is how many delivery outcomes the handler remembers
+const recentSize = 100
+
+// NewHandler creates a new webhook handler delivering to the sinks in notifiers.
+// Accepted notifications are persisted to box before being acknowledged and
+// delivered asynchronously by queueWorkers goroutines from a queue holding at
+// most queueSize pending notifications. Notifications that exhaust their
+// retries are moved to deadLetters. Events silenced in mutes (which may be
+// nil) are acknowledged without being sent, and webhooks already in seen
+// (nil disables duplicate suppression) are acknowledged without being queued.
+// Episode events matching batching are held and delivered as one summary
+// per series.
+func NewHandler(notifiers *notifier.Registry, box *outbox.Outbox, deadLetters *deadletter.Store, mutes *activity.Mutes, seen *dedup.Cache, batching coalesce.Options, queueSize, queueWorkers int) *Handler {
+	log.Println("[DEBUG] [HANDLERS] Creating new webhook handler...")
+
+	if notifiers == nil {
+		notifiers = notifier.NewRegistry()
+	}
+	if notifiers.Len() > 0 {
+		log.Printf("[DEBUG] [HANDLERS] Delivering to sinks: %s", strings.Join(notifiers.Names(), ", "))
+	} else {
+		log.Println("[DEBUG] [HANDLERS] No sinks enabled - notifications will only be logged")
+	}
+
+	h := &Handler{
+		notifiers:   notifiers,
+		outbox:      box,
+		deadLetters: deadLetters,
+		activity:    activity.NewLog(recentSize),
+		mutes:       mutes,
+		seen:        seen,
+		adapters:    Adapters(),
+	}
+	h.queue = queue.New(queueSize, queueWorkers, h.deliver)
+	if batching.Enabled() {
+		h.batches = coalesce.New(batching, h.flushBatch)
+	}
+	return h
 }
 
-// NewHandler creates a new webhook handler with optional Discord bot
-func NewHandler(discordBot DiscordBot) *Handler {
-	log.Println("[DEBUG] [HANDLERS] Creating new webhook handler...")
+// Replay re-queues outbox entries left over from a previous run.
+// It blocks while the queue is full, so callers usually run it in a goroutine.
+func (h *Handler) Replay(ctx context.Context) error {
+	pending := h.outbox.Pending()
+	if len(pending) == 0 {
+		log.Println("[DEBUG] [HANDLERS] No pending outbox entries to replay")
+		return nil
+	}
+
+	log.Printf("[DEBUG] [HANDLERS] Replaying %d pending outbox entries...", len(pending))
+	for _, entry := range pending {
+		if h.hold(entry.ID, entry.Event, entry.Sinks, entry.CreatedAt) {
+			continue
+		}
+		job := queue.Job{ID: entry.ID, Event: entry.Event, Sinks: entry.Sinks, Targets: entry.Targets, AcceptedAt: entry.CreatedAt}
+		if err := h.queue.EnqueueWait(ctx, job); err != nil {
+			return fmt.Errorf("error replaying outbox entry %s: %v", entry.ID, err)
+		}
+	}
+	log.Printf("Replayed %d pending notifications from the outbox", len(pending))
+	return nil
+}
 
-	if discordBot != nil {
-		log.Println("[DEBUG] [HANDLERS] Discord bot integration enabled")
+// Shutdown stops accepting notifications, flushes held episode batches
+// and drains the delivery queue. Batches not queued before ctx expires stay
+// in the outbox and are replayed on the next start.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	if h.batches != nil {
+		h.batches.Close(ctx)
+	}
+	log.Println("[DEBUG] [HANDLERS] Draining delivery queue...")
+	return h.queue.Shutdown(ctx)
+}
+
+// deliver fans a queued notification out to the sinks and acknowledges its
+// outbox entry. Each sink that fails for good gets its own dead letter so a
+// redrive only repeats the failed sink; deliveries interrupted by shutdown
+// stay in the outbox and are replayed on the next start.
+func (h *Handler) deliver(ctx context.Context, job queue.Job) error {
+	if until, muted := h.muted(job.Event.Kind); muted {
+		log.Printf("[DEBUG] [HANDLERS] %s is muted until %s, dropping notification %s", job.Event.Kind, until.Format(time.RFC3339), job.ID)
+		h.record(job, nil, true)
+		if err := h.outbox.Ack(job.ID); err != nil {
+			return fmt.Errorf("error acknowledging outbox entry %s: %v", job.ID, err)
+		}
+		return nil
+	}
+
+	if h.notifiers.Len() == 0 {
+		log.Println("[DEBUG] [HANDLERS] No sinks enabled, skipping delivery")
+		if err := h.outbox.Ack(job.ID); err != nil {
+			return fmt.Errorf("error acknowledging outbox entry %s: %v", job.ID, err)
+		}
+		return nil
+	}
+
+	// Jobs for specific sinks are redrives of dead letters
+	if len(job.Sinks) > 0 {
+		ctx = notifier.WithRedelivery(ctx)
+	}
+	if len(job.Targets) > 0 {
+		ctx = notifier.WithTargets(ctx, job.Targets)
+	}
+	started := time.Now().UTC()
+	err := h.notifiers.SendTo(ctx, job.Sinks, job.Event)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("delivery of %s interrupted, leaving it in the outbox: %v", job.ID, err)
+	}
+
+	if err != nil {
+		failures := map[string]error{"": err}
+		var sendErr *notifier.SendError
+		if errors.As(err, &sendErr) {
+			failures = sendErr.Errors
+		}
+		for sink, sinkErr := range failures {
+			if dlErr := h.deadLetter(job, sink, started, sinkErr); dlErr != nil {
+				// Keep the outbox entry so the notification is not lost
+				return fmt.Errorf("error dead-lettering %s for sink %q: %v (delivery error: %v)", job.ID, sink, dlErr, sinkErr)
+			}
+		}
 	} else {
-		log.Println("[DEBUG] [HANDLERS] No Discord bot provided - running without Discord integration")
+		log.Printf("[DEBUG] [HANDLERS] Notification %s delivered to all sinks", job.ID)
 	}
+	h.record(job, err, false)
 
-	return &Handler{
-		discordBot: discordBot,
+	if ackErr := h.outbox.Ack(job.ID); ackErr != nil {
+		return fmt.Errorf("error acknowledging outbox entry %s: %v", job.ID, ackErr)
 	}
+	if err != nil {
+		return fmt.Errorf("error delivering notification: %v", err)
+	}
+	return nil
 }
 
-// HandleWebhook processes incoming webhook notifications
+// maxPayloadSize bounds the webhook bodies the handler reads
+const maxPayloadSize = 1 << 20
+
+// HandleWebhook processes incoming webhook notifications. The source is
+// detected from the request: Jellyfin, Sonarr and Radarr payloads are
+// recognised and anything else is decoded as an Overseerr notification.
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, h.adapters.Decode)
+}
+
+// HandleSourceWebhook processes notifications posted to /webhook/{source}
+// with that source's adapter
+func (h *Handler) HandleSourceWebhook(w http.ResponseWriter, r *http.Request) {
+	source := r.PathValue("source")
+	adapter, ok := h.adapters.Get(source)
+	if !ok {
+		log.Printf("[WARN] [HANDLERS] Webhook for unknown source %q from %s", source, r.RemoteAddr)
+		http.NotFound(w, r)
+		return
+	}
+	h.handle(w, r, adapter.Decode)
+}
+
+// Adapters returns the built-in source adapters in detection order.
+// Overseerr comes last since it accepts any JSON object.
+func Adapters() events.Adapters {
+	return events.Adapters{jellyfin.Adapter{}, arr.SonarrAdapter{}, arr.RadarrAdapter{}, overseerr.Adapter{}}
+}
+
+// handle validates the request, decodes its body with decode and queues
+// the resulting events
+func (h *Handler) handle(w http.ResponseWriter, r *http.Request, decode func(http.Header, []byte) ([]events.Event, error)) {
 	log.Printf("[DEBUG] [HANDLERS] Incoming request - Method: %s, URL: %s, RemoteAddr: %s", r.Method, r.URL.Path, r.RemoteAddr)
 	log.Printf("[DEBUG] [HANDLERS] Request headers - Content-Type: %s, User-Agent: %s", r.Header.Get("Content-Type"), r.Header.Get("User-Agent"))
 
@@ -60,69 +229,247 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the JSON payload
 	log.Println("[DEBUG] [HANDLERS] Parsing JSON payload...")
-	var notification models.Notification
-	err := json.NewDecoder(r.Body).Decode(&notification)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		log.Printf("[ERROR] [HANDLERS] Error reading body: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	decoded, err := decode(r.Header, body)
 	if err != nil {
 		log.Printf("[ERROR] [HANDLERS] Error parsing JSON: %v", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-	log.Println("[DEBUG] [HANDLERS] JSON payload parsed successfully")
+	log.Printf("[DEBUG] [HANDLERS] JSON payload parsed into %d event(s)", len(decoded))
+
+	// A repeated Idempotency-Key suppresses the whole request
+	requestKey := ""
+	if value := strings.TrimSpace(r.Header.Get(IdempotencyHeader)); value != "" {
+		requestKey = dedup.HeaderKey(value)
+		if first, ok := h.claim(requestKey); !ok {
+			log.Printf("[DEBUG] [HANDLERS] Idempotency-Key %q already accepted at %s, suppressing", value, first.Format(time.RFC3339))
+			suppressed(w)
+			return
+		}
+	}
 
-	// Log the received notification
-	log.Printf("Received notification:")
-	log.Printf("  Type: %s", notification.NotificationType)
-	log.Printf("  Event: %s", notification.Event)
-	log.Printf("  Subject: %s", notification.Subject)
-	log.Printf("  Message: %s", notification.Message)
-	log.Printf("  Image: %s", notification.Image)
+	// Persist and queue the events before acknowledging the webhook. Events
+	// queued before a failure are delivered even though the sender retries;
+	// their content keys stay claimed, so only an Idempotency-Key retry
+	// repeats them.
+	accepted := 0
+	for _, event := range decoded {
+		logEvent(event)
+
+		key := ""
+		if requestKey == "" {
+			key = dedup.Key(event)
+			if first, ok := h.claim(key); !ok {
+				log.Printf("[DEBUG] [HANDLERS] Duplicate %s event %q first accepted at %s, suppressing", event.Kind, event.Title, first.Format(time.RFC3339))
+				continue
+			}
+		}
+
+		if _, err := h.accept(event, nil, nil); err != nil {
+			h.release(key)
+			h.release(requestKey)
+			if errors.Is(err, errPersist) {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		accepted++
+	}
+
+	if accepted == 0 && len(decoded) > 0 {
+		suppressed(w)
+		return
+	}
+
+	// Send an accepted response
+	log.Println("[DEBUG] [HANDLERS] Notification queued, sending accepted response")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(w, "Notification accepted")
+	log.Println("[DEBUG] [HANDLERS] Webhook processing completed successfully")
+}
+
+// IdempotencyHeader carries a sender-chosen key; webhooks repeating a key
+// seen within the dedup window are suppressed
+const IdempotencyHeader = "Idempotency-Key"
+
+// SuppressedHeader is set to "suppressed" on responses to duplicate webhooks
+const SuppressedHeader = "X-Jellynotifier-Duplicate"
+
+// suppressed answers a duplicate webhook with 200 so the sender stops retrying
+func suppressed(w http.ResponseWriter) {
+	w.Header().Set(SuppressedHeader, "suppressed")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "Duplicate notification suppressed")
+}
+
+// claim records a dedup key and reports whether it is new; every key is
+// new when duplicate suppression is disabled
+func (h *Handler) claim(key string) (time.Time, bool) {
+	if h.seen == nil {
+		return time.Time{}, true
+	}
+	return h.seen.Claim(key)
+}
+
+// release forgets a dedup key claimed for a webhook that was not accepted
+func (h *Handler) release(key string) {
+	if h.seen != nil && key != "" {
+		h.seen.Release(key)
+	}
+}
+
+// logEvent logs a received event
+func logEvent(event events.Event) {
+	log.Printf("Received %s event:", event.Source)
+	log.Printf("  Type: %s", event.Type)
+	log.Printf("  Event: %s", event.Kind)
+	log.Printf("  Title: %s", event.Title)
+	log.Printf("  Body: %s", event.Body)
+	log.Printf("  Image: %s", event.Image())
 
 	// Log media information if available
-	if notification.Media.MediaType != "" {
-		log.Printf("  Media Type: %s", notification.Media.MediaType)
-		log.Printf("  TMDB ID: %s", notification.Media.TmdbId)
-		log.Printf("  TVDB ID: %s", notification.Media.TvdbId)
-		log.Printf("  Status: %s", notification.Media.Status)
-		log.Printf("  Status 4K: %s", notification.Media.Status4k)
+	if event.Media.Type != "" {
+		log.Printf("  Media Type: %s", event.Media.Type)
+		log.Printf("  Media Title: %s", event.Media.Title)
+		log.Printf("  TMDB ID: %s", event.Media.TmdbID)
+		log.Printf("  TVDB ID: %s", event.Media.TvdbID)
+		log.Printf("  Status: %s", event.Media.Status)
+		log.Printf("  Status 4K: %s", event.Media.Status4k)
 	}
 
-	// Log request information if available
-	if notification.Request.RequestID != "" {
-		log.Printf("  Request ID: %s", notification.Request.RequestID)
-		log.Printf("  Requested By: %s (%s)", notification.Request.RequestedByUsername, notification.Request.RequestedByEmail)
+	if event.RequestID != "" {
+		log.Printf("  Request ID: %s", event.RequestID)
+	}
+	if event.Issue.ID != "" {
+		log.Printf("  Issue ID: %s", event.Issue.ID)
+		log.Printf("  Issue Type: %s", event.Issue.Type)
+		log.Printf("  Issue Status: %s", event.Issue.Status)
+	}
+	if event.Comment != "" {
+		log.Printf("  Comment: %s", event.Comment)
+	}
+	for _, actor := range event.Actors {
+		log.Printf("  %s: %s (%s)", actor.Role, actor.Name, actor.Email)
 	}
+}
 
-	// Log issue information if available
-	if notification.Issue.IssueID != "" {
-		log.Printf("  Issue ID: %s", notification.Issue.IssueID)
-		log.Printf("  Issue Type: %s", notification.Issue.IssueType)
-		log.Printf("  Issue Status: %s", notification.Issue.IssueStatus)
-		log.Printf("  Reported By: %s (%s)", notification.Issue.ReportedByUsername, notification.Issue.ReportedByEmail)
+// errPersist marks failures to write the outbox, as opposed to a full queue
+var errPersist = errors.New("error persisting notification")
+
+// accept writes an event to the outbox and hands it to the delivery queue
+// for the given sinks (all when empty) and targets within them. If the queue refuses it the outbox entry
+// is dropped again, since the caller reports the failure to whoever sent it.
+// Episode events for all sinks may be held for a series summary instead.
+func (h *Handler) accept(event events.Event, sinks, targets []string) (outbox.Entry, error) {
+	entry, err := h.outbox.Append(event, sinks, targets)
+	if err != nil {
+		log.Printf("[ERROR] [HANDLERS] Failed to persist notification: %v", err)
+		return outbox.Entry{}, fmt.Errorf("%w: %v", errPersist, err)
+	}
+	if h.hold(entry.ID, event, sinks, entry.CreatedAt) {
+		return entry, nil
 	}
 
-	// Log comment information if available
-	if notification.Comment.CommentMessage != "" {
-		log.Printf("  Comment: %s", notification.Comment.CommentMessage)
-		log.Printf("  Commented By: %s (%s)", notification.Comment.CommentedByUsername, notification.Comment.CommentedByEMail)
+	job := queue.Job{ID: entry.ID, Event: event, Sinks: sinks, Targets: targets, AcceptedAt: entry.CreatedAt}
+	if err := h.queue.Enqueue(job); err != nil {
+		if errors.Is(err, queue.ErrFull) {
+			log.Printf("[WARN] [HANDLERS] Delivery queue full, rejecting notification: %v", err)
+		} else {
+			log.Printf("[ERROR] [HANDLERS] Failed to enqueue notification: %v", err)
+		}
+		if ackErr := h.outbox.Ack(entry.ID); ackErr != nil {
+			log.Printf("[ERROR] [HANDLERS] Failed to drop rejected outbox entry %s: %v", entry.ID, ackErr)
+		}
+		return outbox.Entry{}, err
+	}
+	return entry, nil
+}
+
+// hold hands an outbox entry for all sinks to the episode batcher and
+// reports whether it is held there instead of being queued
+func (h *Handler) hold(id string, event events.Event, sinks []string, acceptedAt time.Time) bool {
+	if h.batches == nil || len(sinks) > 0 {
+		return false
 	}
+	return h.batches.Add(coalesce.Item{ID: id, Event: event, AcceptedAt: acceptedAt})
+}
 
-	// Send to Discord if bot is available
-	if h.discordBot != nil {
-		log.Println("[DEBUG] [HANDLERS] Discord bot available, sending notification...")
-		if err := h.discordBot.SendNotification(notification); err != nil {
-			log.Printf("[ERROR] [HANDLERS] Error sending notification to Discord: %v", err)
+// flushBatch queues a finished episode batch. Several episodes are
+// replaced by one summary entry in the outbox; if the summary cannot be
+// persisted the episodes are queued one by one. Entries that cannot be
+// queued before ctx is cancelled stay in the outbox for the next start.
+func (h *Handler) flushBatch(ctx context.Context, items []coalesce.Item) {
+	if len(items) > 1 {
+		batch := make([]events.Event, 0, len(items))
+		for _, item := range items {
+			batch = append(batch, item.Event)
+		}
+		summary := coalesce.Summary(batch)
+
+		entry, err := h.outbox.Append(summary, nil, nil)
+		if err == nil {
+			log.Printf("[DEBUG] [HANDLERS] Coalesced %d notifications into %s: %s", len(items), entry.ID, summary.Title)
+			for _, item := range items {
+				if err := h.outbox.Ack(item.ID); err != nil {
+					log.Printf("[ERROR] [HANDLERS] Failed to acknowledge coalesced outbox entry %s: %v", item.ID, err)
+				}
+			}
+			items = []coalesce.Item{{ID: entry.ID, Event: summary, AcceptedAt: items[0].AcceptedAt}}
 		} else {
-			log.Println("[DEBUG] [HANDLERS] Discord notification sent successfully")
+			log.Printf("[ERROR] [HANDLERS] Failed to persist summary, delivering %d notifications separately: %v", len(items), err)
 		}
-	} else {
-		log.Println("[DEBUG] [HANDLERS] No Discord bot configured, skipping Discord notification")
 	}
 
-	// Send a success response
-	log.Println("[DEBUG] [HANDLERS] Sending success response")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, "Notification received")
-	log.Println("[DEBUG] [HANDLERS] Webhook processing completed successfully")
+	for i, item := range items {
+		if ctx.Err() != nil {
+			log.Printf("[WARN] [HANDLERS] Stopped queueing batch, leaving %d notification(s) in the outbox: %v", len(items)-i, ctx.Err())
+			return
+		}
+		job := queue.Job{ID: item.ID, Event: item.Event, AcceptedAt: item.AcceptedAt}
+		if err := h.queue.EnqueueWait(ctx, job); err != nil {
+			log.Printf("[ERROR] [HANDLERS] Failed to queue %s, leaving it in the outbox: %v", item.ID, err)
+		}
+	}
+}
+
+// deadLetter records a sink's failed delivery in the dead-letter store,
+// along with the destinations that failed when the sink reports them
+func (h *Handler) deadLetter(job queue.Job, sink string, started time.Time, err error) error {
+	attempts := 1
+	var retryErr *retry.Error
+	if errors.As(err, &retryErr) {
+		attempts = retryErr.Attempts
+	}
+	var targets []string
+	var targetErr *notifier.TargetError
+	if errors.As(err, &targetErr) {
+		targets = targetErr.Targets
+	}
+
+	id := job.ID
+	if sink != "" {
+		id = job.ID + "-" + sink
+	}
+	return h.deadLetters.Add(deadletter.Letter{
+		ID:           id,
+		Event:        job.Event,
+		Sink:         sink,
+		Targets:      targets,
+		Error:        err.Error(),
+		Attempts:     attempts,
+		AcceptedAt:   job.AcceptedAt,
+		FirstAttempt: started,
+		LastAttempt:  time.Now().UTC(),
+	})
 }
 
 // HealthHandler provides a simple health check endpoint
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"jellynotifier/activity"
+	"jellynotifier/queue"
+)
+
+// Status reports uptime, queue depth, delivery counters and active mutes
+func (h *Handler) Status() activity.Status {
+	status := h.activity.Status()
+	status.QueueDepth = h.queue.Len()
+	status.QueueCapacity = h.queue.Cap()
+	status.DeadLetters = h.deadLetters.Len()
+	status.Sinks = h.notifiers.Names()
+	if h.mutes != nil {
+		status.Muted = h.mutes.Active()
+	}
+	return status
+}
+
+// Recent returns up to count recent delivery outcomes for events matching
+// pattern, newest first
+func (h *Handler) Recent(pattern string, count int) []activity.Record {
+	return h.activity.Recent(pattern, count)
+}
+
+// Mute silences events matching pattern for d, or lifts the mute when d
+// is not positive, and returns when the mute ends
+func (h *Handler) Mute(pattern string, d time.Duration) (time.Time, error) {
+	if h.mutes == nil {
+		return time.Time{}, fmt.Errorf("muting is not available")
+	}
+	until := time.Now().Add(d)
+	if d <= 0 {
+		until = time.Time{}
+	}
+	if err := h.mutes.Mute(pattern, until); err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// muted reports whether the event is muted and until when
+func (h *Handler) muted(event string) (time.Time, bool) {
+	if h.mutes == nil {
+		return time.Time{}, false
+	}
+	return h.mutes.Muted(event)
+}
+
+// record adds a job's delivery outcome to the activity log
+func (h *Handler) record(job queue.Job, err error, muted bool) {
+	sinks := job.Sinks
+	if len(sinks) == 0 {
+		sinks = h.notifiers.Names()
+	}
+	record := activity.Record{
+		ID:      job.ID,
+		Event:   job.Event.Kind,
+		Subject: job.Event.Title,
+		Sinks:   sinks,
+		At:      time.Now().UTC(),
+		Muted:   muted,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	h.activity.Add(record)
+}
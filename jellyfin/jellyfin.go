@@ -0,0 +1,247 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"jellynotifier/events"
+	"jellynotifier/models"
+)
+
+// kinds maps the plugin's notification types onto the event names used
+// for routing, templates and colours. Types not listed become
+// "jellyfin.<snake_case type>".
+var kinds = map[string]string{
+	"ItemAdded":               "item.added",
+	"ItemDeleted":             "item.deleted",
+	"PlaybackStart":           "playback.started",
+	"PlaybackStop":            "playback.stopped",
+	"PlaybackProgress":        "playback.progress",
+	"SessionStart":            "session.started",
+	"UserCreated":             "user.created",
+	"UserDeleted":             "user.deleted",
+	"UserLockedOut":           "user.locked_out",
+	"UserPasswordChanged":     "user.password_changed",
+	"AuthenticationFailure":   "auth.failed",
+	"AuthenticationSuccess":   "auth.succeeded",
+	"PluginInstalled":         "plugin.installed",
+	"PluginUninstalled":       "plugin.uninstalled",
+	"PluginUpdated":           "plugin.updated",
+	"PendingRestart":          "server.restart_pending",
+	"TaskCompleted":           "task.completed",
+	"SubtitleDownloadFailure": "subtitle.download_failed",
+}
+
+// Adapter converts Jellyfin Webhook plugin notifications into events
+type Adapter struct{}
+
+// Source implements events.Adapter
+func (Adapter) Source() string {
+	return "jellyfin"
+}
+
+// Detect implements events.Adapter
+func (Adapter) Detect(header http.Header, body []byte) bool {
+	return Detect(body)
+}
+
+// Decode implements events.Adapter
+func (Adapter) Decode(header http.Header, body []byte) ([]events.Event, error) {
+	event, err := Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	return []events.Event{event}, nil
+}
+
+// Detect reports whether body looks like a Jellyfin Webhook plugin payload
+// rather than an Overseerr notification
+func Detect(body []byte) bool {
+	var probe struct {
+		NotificationType string `json:"NotificationType"`
+		ServerID         string `json:"ServerId"`
+		ServerName       string `json:"ServerName"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return false
+	}
+	// Overseerr's key is notification_type, which never fills NotificationType
+	return probe.NotificationType != "" && (probe.ServerID != "" || probe.ServerName != "")
+}
+
+// Decode parses a Jellyfin Webhook plugin payload into an event
+func Decode(body []byte) (events.Event, error) {
+	var payload models.JellyfinPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return events.Event{}, fmt.Errorf("error decoding Jellyfin payload: %v", err)
+	}
+	if payload.NotificationType == "" {
+		return events.Event{}, fmt.Errorf("jellyfin payload has no NotificationType")
+	}
+	log.Printf("[DEBUG] [JELLYFIN] Decoded %s for %q", payload.NotificationType, payload.Name)
+	return Event(payload), nil
+}
+
+// Event maps a Jellyfin payload onto an event: a title and body per type,
+// the item's poster, provider IDs, the user and the remaining context as
+// attributes
+func Event(p models.JellyfinPayload) events.Event {
+	event := events.Event{
+		Source: "jellyfin",
+		Kind:   Kind(p.NotificationType),
+		Type:   p.NotificationType,
+	}
+	event.AddImage(events.ImagePoster, imageURL(p))
+
+	title := itemTitle(p)
+	user := events.FirstNonEmpty(p.NotificationUsername, p.Username)
+
+	switch p.NotificationType {
+	case "ItemAdded":
+		event.Title = fmt.Sprintf("New %s: %s", itemKind(p.ItemType), title)
+		event.Body = p.Overview
+	case "ItemDeleted":
+		event.Title = fmt.Sprintf("Removed from library: %s", title)
+	case "PlaybackStart":
+		event.Title = fmt.Sprintf("%s started playing %s", events.FirstNonEmpty(user, "Someone"), title)
+	case "PlaybackStop":
+		verb := "stopped"
+		if p.PlayedToCompletion.Bool() {
+			verb = "finished"
+		}
+		event.Title = fmt.Sprintf("%s %s %s", events.FirstNonEmpty(user, "Someone"), verb, title)
+	case "PlaybackProgress":
+		event.Title = fmt.Sprintf("%s is playing %s", events.FirstNonEmpty(user, "Someone"), title)
+	case "SessionStart":
+		event.Title = fmt.Sprintf("%s started a session", events.FirstNonEmpty(user, "Someone"))
+	case "UserCreated":
+		event.Title = fmt.Sprintf("New Jellyfin user: %s", user)
+	case "UserDeleted":
+		event.Title = fmt.Sprintf("Jellyfin user deleted: %s", user)
+	case "UserLockedOut":
+		event.Title = fmt.Sprintf("Jellyfin user locked out: %s", user)
+		event.Body = "The account was locked after too many failed logins."
+	case "UserPasswordChanged":
+		event.Title = fmt.Sprintf("Password changed for %s", user)
+	case "AuthenticationFailure":
+		event.Title = fmt.Sprintf("Failed login for %s", events.FirstNonEmpty(user, "unknown user"))
+	case "AuthenticationSuccess":
+		event.Title = fmt.Sprintf("%s signed in", user)
+	case "PluginInstalled", "PluginUninstalled", "PluginUpdated":
+		action := strings.ToLower(strings.TrimPrefix(p.NotificationType, "Plugin"))
+		event.Title = strings.TrimSpace(fmt.Sprintf("Plugin %s: %s %s", action, p.PluginName, p.PluginVersion))
+	case "PendingRestart":
+		event.Title = fmt.Sprintf("%s needs a restart", events.FirstNonEmpty(p.ServerName, "Jellyfin"))
+	case "TaskCompleted":
+		event.Title = fmt.Sprintf("Task finished: %s", p.TaskName)
+		event.Body = p.ResultErrorMessage
+	case "SubtitleDownloadFailure":
+		event.Title = fmt.Sprintf("Subtitle download failed: %s", title)
+	default:
+		event.Title = strings.TrimSpace(p.NotificationType + ": " + title)
+	}
+
+	switch strings.ToLower(p.ItemType) {
+	case "movie":
+		event.Media.Type = "movie"
+		event.Media.Title = p.Name
+	case "episode", "season", "series":
+		event.Media.Type = "tv"
+		event.Media.Title = events.FirstNonEmpty(p.SeriesName, p.Name)
+		event.Media.Season, _ = strconv.Atoi(number(p.SeasonNumber))
+		if episode, err := strconv.Atoi(number(p.EpisodeNumber)); err == nil && strings.EqualFold(p.ItemType, "episode") {
+			event.Media.Episodes = []int{episode}
+		}
+	}
+	event.Media.TmdbID = p.ProviderTmdb
+	event.Media.TvdbID = p.ProviderTvdb
+	event.Media.ImdbID = p.ProviderImdb
+	event.AddActor(events.Actor{Role: events.RoleUser, Name: user})
+
+	event.SetAttr("Device", strings.TrimSpace(p.DeviceName+" "+parenthesised(p.ClientName)))
+	event.SetAttr("Address", p.RemoteEndPoint)
+	event.SetAttr("Position", p.PlaybackPosition)
+	event.SetAttr("Runtime", p.RunTime)
+	event.SetAttr("Status", p.ResultStatus)
+	event.SetAttr("IMDb", p.ProviderImdb)
+	event.SetAttr("Server", p.ServerName)
+	return event
+}
+
+// Kind returns the event name for a plugin notification type
+func Kind(notificationType string) string {
+	if kind, ok := kinds[notificationType]; ok {
+		return kind
+	}
+	return "jellyfin." + events.SnakeCase(notificationType)
+}
+
+// itemTitle formats the item as "Series S01E02 - Episode", "Movie (2024)" or its name
+func itemTitle(p models.JellyfinPayload) string {
+	switch strings.ToLower(p.ItemType) {
+	case "episode":
+		if p.SeriesName == "" {
+			return p.Name
+		}
+		title := p.SeriesName
+		if season, episode := number(p.SeasonNumber), number(p.EpisodeNumber); season != "" && episode != "" {
+			title += fmt.Sprintf(" S%02sE%02s", season, episode)
+		}
+		if p.Name != "" {
+			title += " - " + p.Name
+		}
+		return title
+	case "season":
+		return strings.TrimSpace(p.SeriesName + " " + p.Name)
+	}
+	if p.Year != "" && p.Name != "" {
+		return fmt.Sprintf("%s (%s)", p.Name, p.Year)
+	}
+	return events.FirstNonEmpty(p.Name, p.ItemType, "item")
+}
+
+// itemKind names the item type in a subject
+func itemKind(itemType string) string {
+	switch strings.ToLower(itemType) {
+	case "":
+		return "item"
+	case "musicalbum":
+		return "album"
+	case "audio":
+		return "track"
+	default:
+		return strings.ToLower(itemType)
+	}
+}
+
+// imageURL returns the item's primary image on the server, or "" when the
+// payload lacks the server URL or item ID
+func imageURL(p models.JellyfinPayload) string {
+	if p.ServerURL == "" || p.ItemID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/Items/%s/Images/Primary", strings.TrimRight(p.ServerURL, "/"), p.ItemID)
+}
+
+// number returns a season or episode number, or "" when it is not numeric
+func number(value models.FlexString) string {
+	for _, r := range value {
+		if !unicode.IsDigit(r) {
+			return ""
+		}
+	}
+	return string(value)
+}
+
+// parenthesised wraps s in parentheses, or returns "" for an empty s
+func parenthesised(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "(" + s + ")"
+}
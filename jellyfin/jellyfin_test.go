@@ -0,0 +1,187 @@
+package jellyfin_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"jellynotifier/events"
+	"jellynotifier/handlers"
+	"jellynotifier/jellyfin"
+	"jellynotifier/models"
+	"jellynotifier/overseerr"
+)
+
+func payload(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file   string
+		kind   string
+		title  string
+		body   string
+		media  events.Media
+		poster string
+		user   string
+		attrs  map[string]string
+	}{
+		{
+			file:   "item_added_episode.json",
+			kind:   "item.added",
+			title:  "New episode: Severance S02E03 - Who Is Alive?",
+			body:   "Mark and the team meet a new colleague.",
+			media:  events.Media{Type: "tv", Title: "Severance", TmdbID: "5826212", TvdbID: "10393042", ImdbID: "tt13808936", Season: 2, Episodes: []int{3}},
+			poster: "https://jellyfin.example.com/Items/5d0c3b1a9e7f4a2c8b6d4e2f1a3c5b7d/Images/Primary",
+			attrs:  map[string]string{"Runtime": "00:48:03", "Server": "media"},
+		},
+		{
+			file:   "item_added_movie.json",
+			kind:   "item.added",
+			title:  "New movie: Dune: Part Two (2024)",
+			body:   "Paul Atreides unites with Chani and the Fremen.",
+			media:  events.Media{Type: "movie", Title: "Dune: Part Two", TmdbID: "693134", ImdbID: "tt15239678"},
+			poster: "https://jellyfin.example.com/Items/c1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6/Images/Primary",
+			attrs:  map[string]string{"IMDb": "tt15239678"},
+		},
+		{
+			file:   "playback_start.json",
+			kind:   "playback.started",
+			title:  "alice started playing The Bear S01E07 - Review",
+			media:  events.Media{Type: "tv", Title: "The Bear", TvdbID: "9156108", Season: 1, Episodes: []int{7}},
+			poster: "https://jellyfin.example.com/Items/0a1b2c3d4e5f60718293a4b5c6d7e8f9/Images/Primary",
+			user:   "alice",
+			attrs:  map[string]string{"Device": "Living Room TV (Jellyfin Android TV)", "Address": "192.168.1.40", "Position": "00:00:00"},
+		},
+		{
+			file:   "playback_stop.json",
+			kind:   "playback.stopped",
+			title:  "bob finished Dune: Part Two (2024)",
+			media:  events.Media{Type: "movie", Title: "Dune: Part Two", TmdbID: "693134", ImdbID: "tt15239678"},
+			poster: "https://jellyfin.example.com/Items/c1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6/Images/Primary",
+			user:   "bob",
+			attrs:  map[string]string{"Device": "Firefox (Jellyfin Web)", "Position": "02:45:41", "Runtime": "02:45:42"},
+		},
+		{
+			file:  "user_created.json",
+			kind:  "user.created",
+			title: "New Jellyfin user: carol",
+			user:  "carol",
+		},
+		{
+			file:  "authentication_failure.json",
+			kind:  "auth.failed",
+			title: "Failed login for admin",
+			user:  "admin",
+			attrs: map[string]string{"Device": "Chrome", "Address": "198.51.100.23"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			body := payload(t, tt.file)
+			if !jellyfin.Detect(body) {
+				t.Fatal("payload is not detected as Jellyfin")
+			}
+			event, err := jellyfin.Decode(body)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			if event.Source != "jellyfin" || event.Kind != tt.kind {
+				t.Errorf("source and kind = %s, %s; want jellyfin, %s", event.Source, event.Kind, tt.kind)
+			}
+			if event.Title != tt.title {
+				t.Errorf("title = %q, want %q", event.Title, tt.title)
+			}
+			if event.Body != tt.body {
+				t.Errorf("body = %q, want %q", event.Body, tt.body)
+			}
+			if !reflect.DeepEqual(event.Media, tt.media) {
+				t.Errorf("media = %+v, want %+v", event.Media, tt.media)
+			}
+			if event.Image() != tt.poster {
+				t.Errorf("poster = %q, want %q", event.Image(), tt.poster)
+			}
+			if user := event.Actor(events.RoleUser).Name; user != tt.user {
+				t.Errorf("user = %q, want %q", user, tt.user)
+			}
+			for name, value := range tt.attrs {
+				if got := event.Attr(name); got != value {
+					t.Errorf("attribute %s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestEpisodeTitle(t *testing.T) {
+	tests := []struct {
+		season  models.FlexString
+		episode models.FlexString
+		want    string
+	}{
+		{"1", "7", "New episode: The Bear S01E07 - Review"},
+		{"10", "12", "New episode: The Bear S10E12 - Review"},
+		{"2", "105", "New episode: The Bear S02E105 - Review"},
+		{"0", "3", "New episode: The Bear S00E03 - Review"},
+		// Numbers the template left empty or unrendered are omitted
+		{"", "7", "New episode: The Bear - Review"},
+		{"1", "{{EpisodeNumber}}", "New episode: The Bear - Review"},
+	}
+
+	for _, tt := range tests {
+		event := jellyfin.Event(models.JellyfinPayload{
+			NotificationType: "ItemAdded",
+			ItemType:         "Episode",
+			Name:             "Review",
+			SeriesName:       "The Bear",
+			SeasonNumber:     tt.season,
+			EpisodeNumber:    tt.episode,
+		})
+		if event.Title != tt.want {
+			t.Errorf("season %q, episode %q: title = %q, want %q", tt.season, tt.episode, event.Title, tt.want)
+		}
+	}
+}
+
+func TestAdaptersDetectJellyfinBeforeOverseerr(t *testing.T) {
+	adapters := handlers.Adapters()
+	for _, file := range []string{
+		"item_added_episode.json",
+		"item_added_movie.json",
+		"playback_start.json",
+		"playback_stop.json",
+		"user_created.json",
+		"authentication_failure.json",
+	} {
+		body := payload(t, file)
+		// Overseerr accepts any JSON object, so the order decides
+		if !(overseerr.Adapter{}).Detect(http.Header{}, body) {
+			t.Fatalf("%s: expected the Overseerr catch-all to accept the payload too", file)
+		}
+		decoded, err := adapters.Decode(http.Header{}, body)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", file, err)
+			continue
+		}
+		if len(decoded) != 1 || decoded[0].Source != "jellyfin" {
+			t.Errorf("%s: decoded %+v, want one Jellyfin event", file, decoded)
+		}
+	}
+}
+
+func TestDetectRejectsOverseerr(t *testing.T) {
+	body := []byte(`{"notification_type":"MEDIA_AVAILABLE","event":"media.available","subject":"Dune"}`)
+	if jellyfin.Detect(body) {
+		t.Error("an Overseerr payload was detected as Jellyfin")
+	}
+}
@@ -1,23 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"jellynotifier/activity"
+	"jellynotifier/auth"
+	"jellynotifier/coalesce"
 	"jellynotifier/config"
+	"jellynotifier/deadletter"
+	"jellynotifier/dedup"
+	"jellynotifier/digest"
 	"jellynotifier/discord"
 	"jellynotifier/handlers"
+	"jellynotifier/notifier"
+	"jellynotifier/outbox"
+	"jellynotifier/overseerr"
 	"jellynotifier/server"
+	"jellynotifier/slack"
+	"jellynotifier/telegram"
+	"jellynotifier/templates"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:]))
+	}
+
 	log.Println("[DEBUG] Starting JellyNotifier application...")
 
+	configPath := flag.String("config", os.Getenv(config.PathEnv), "path to a YAML, JSON or TOML config file (env: "+config.PathEnv+")")
+	flag.Parse()
+
 	// Load configuration with validation
-	log.Println("[DEBUG] Loading configuration from environment variables...")
-	cfg, err := config.Load()
+	log.Printf("[DEBUG] Loading configuration (file: %q) with environment overrides...", *configPath)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("[ERROR] Configuration error: %v", err)
 	}
@@ -25,59 +47,105 @@ func main() {
 
 	log.Printf("Starting JellyNotifier on port %s", cfg.Port)
 
-	var discordBot *discord.Bot
+	// Per-event message templates, parsed up front so a broken one fails startup
+	set, err := templates.Load(cfg.TemplatesDir)
+	if err != nil {
+		log.Fatalf("[ERROR] Template error: %v", err)
+	}
 
-	// Initialize Discord bot if enabled and configured
-	if cfg.EnableDiscord && cfg.DiscordToken != "" && cfg.DiscordChannel != "" {
-		log.Println("[DEBUG] Discord is enabled and configured, initializing bot...")
-		log.Printf("[DEBUG] Discord Channel ID: %s", cfg.DiscordChannel)
-		log.Printf("[DEBUG] Discord Token length: %d characters", len(cfg.DiscordToken))
+	// Discord messages posted per request, edited as the request progresses
+	tracker, err := discord.OpenTracker(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to open Discord message tracker: %v", err)
+	}
 
-		discordBot, err = discord.NewBot(cfg.DiscordToken, cfg.DiscordChannel)
-		if err != nil {
-			log.Fatalf("[ERROR] Failed to create Discord bot: %v", err)
+	// Build and start every enabled sink
+	notifiers, err := buildNotifiers(cfg, set, tracker)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to initialize notification sinks: %v", err)
+	}
+	defer func() {
+		log.Println("[DEBUG] Graceful shutdown: Stopping notification sinks...")
+		if err := notifiers.Stop(); err != nil {
+			log.Printf("[ERROR] Error stopping notification sinks: %v", err)
+		} else {
+			log.Println("[DEBUG] Notification sinks stopped successfully")
 		}
-		log.Println("[DEBUG] Discord bot instance created successfully")
+	}()
 
-		log.Println("[DEBUG] Starting Discord bot connection...")
-		if err := discordBot.Start(); err != nil {
-			log.Fatalf("[ERROR] Failed to start Discord bot: %v", err)
-		}
-		log.Println("Discord bot connected successfully")
-
-		defer func() {
-			log.Println("[DEBUG] Graceful shutdown: Disconnecting Discord bot...")
-			if err := discordBot.Stop(); err != nil {
-				log.Printf("[ERROR] Error stopping Discord bot: %v", err)
-			} else {
-				log.Println("[DEBUG] Discord bot disconnected successfully")
-			}
-		}()
-	} else {
-		log.Println("[DEBUG] Discord integration disabled or not configured")
-		if !cfg.EnableDiscord {
-			log.Println("[DEBUG] - Discord disabled by ENABLE_DISCORD=false")
-		}
-		if cfg.DiscordToken == "" {
-			log.Println("[DEBUG] - Missing DISCORD_TOKEN environment variable")
-		}
-		if cfg.DiscordChannel == "" {
-			log.Println("[DEBUG] - Missing DISCORD_CHANNEL_ID environment variable")
+	// Open the durable outbox
+	log.Printf("[DEBUG] Opening outbox in %s...", cfg.DataDir)
+	box, err := outbox.Open(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to open outbox: %v", err)
+	}
+	defer func() {
+		if err := box.Close(); err != nil {
+			log.Printf("[ERROR] Error closing outbox: %v", err)
 		}
+	}()
+
+	// Open the dead-letter store
+	deadLetters, err := deadletter.Open(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to open dead-letter store: %v", err)
+	}
+
+	// Event types muted from Discord with /jellynotifier mute
+	mutes, err := activity.OpenMutes(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to open mutes: %v", err)
+	}
+
+	// Recently accepted webhooks, so repeats can be suppressed
+	seen, err := openDedup(cfg)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to open dedup cache: %v", err)
 	}
 
 	// Initialize webhook handler
 	log.Println("[DEBUG] Initializing webhook handler...")
-	webhookHandler := handlers.NewHandler(discordBot)
+	webhookHandler := handlers.NewHandler(notifiers, box, deadLetters, mutes, seen, coalescing(cfg), cfg.QueueSize, cfg.QueueWorkers)
 	log.Println("[DEBUG] Webhook handler created successfully")
 
+	// The handler answers the Discord slash commands
+	if sink, ok := notifiers.Get("discord"); ok {
+		if bot, ok := sink.(*discord.Bot); ok {
+			bot.SetController(webhookHandler)
+		}
+	}
+
+	// Re-deliver notifications accepted before the last shutdown or crash
+	replayCtx, cancelReplay := context.WithCancel(context.Background())
+	defer cancelReplay()
+	go func() {
+		if err := webhookHandler.Replay(replayCtx); err != nil {
+			log.Printf("[ERROR] Outbox replay stopped: %v", err)
+		}
+	}()
+
 	// Set global handler for backward compatibility
 	log.Println("[DEBUG] Setting global handler for backward compatibility...")
 	handlers.SetGlobalHandler(webhookHandler)
 
+	// Initialize webhook authentication
+	log.Println("[DEBUG] Initializing webhook authentication...")
+	verifier := auth.NewVerifier(auth.Config{
+		Token:           cfg.WebhookToken,
+		TokenHeader:     cfg.WebhookTokenHeader,
+		HMACSecret:      cfg.WebhookHMACSecret,
+		SignatureHeader: cfg.WebhookSignatureHeader,
+		TimestampHeader: cfg.WebhookTimestampHeader,
+		MaxSkew:         cfg.WebhookMaxSkew,
+	})
+
+	// Admin endpoints accept only the admin bearer token
+	adminAuth := auth.NewVerifier(auth.Config{Token: cfg.AdminToken})
+
 	// Initialize server
 	log.Printf("[DEBUG] Initializing HTTP server on port %s...", cfg.Port)
-	srv := server.New(cfg.Port, webhookHandler)
+	srv := server.New(cfg.Port, webhookHandler, verifier, adminAuth)
+	srv.ShutdownTimeout = cfg.ShutdownTimeout
 	log.Println("[DEBUG] Server instance created successfully")
 
 	// Start server in a goroutine
@@ -89,6 +157,21 @@ func main() {
 		}
 	}()
 
+	// Re-read the config file and templates on SIGHUP or when they change
+	reloader := newReloader(*configPath, cfg, set, notifiers, tracker, webhookHandler)
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go config.Watch(watchCtx, cfg.WatchInterval, reloader.Reload, *configPath, cfg.TemplatesDir)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			log.Println("[DEBUG] Received SIGHUP, reloading configuration...")
+			reloader.Reload()
+		}
+	}()
+
 	log.Println("[DEBUG] Server started, waiting for shutdown signal...")
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
@@ -97,7 +180,10 @@ func main() {
 	receivedSignal := <-quit
 	log.Printf("[DEBUG] Received shutdown signal: %v", receivedSignal)
 
-	log.Println("Shutting down server...")
+	cancelReplay()
+	stopWatching()
+	signal.Stop(hangup)
+	log.Println("Shutting down server and draining queued notifications...")
 	if err := srv.Shutdown(); err != nil {
 		log.Printf("[ERROR] Error during server shutdown: %v", err)
 	} else {
@@ -107,3 +193,188 @@ func main() {
 	log.Println("Server stopped")
 	log.Println("[DEBUG] JellyNotifier application shutdown complete")
 }
+
+// openDedup creates the duplicate webhook cache, or returns nil when
+// suppression is disabled
+func openDedup(cfg *config.Config) (*dedup.Cache, error) {
+	if cfg.DedupWindow <= 0 {
+		log.Println("[DEBUG] Duplicate suppression disabled")
+		return nil, nil
+	}
+	if cfg.DedupPersist {
+		return dedup.Open(cfg.DataDir, cfg.DedupWindow, cfg.DedupSize)
+	}
+	return dedup.New(cfg.DedupWindow, cfg.DedupSize), nil
+}
+
+// coalescing builds the episode batching options from the configuration
+func coalescing(cfg *config.Config) coalesce.Options {
+	return coalesce.Options{
+		Window:   cfg.CoalesceWindow,
+		MaxWait:  cfg.CoalesceMaxWait,
+		MaxItems: cfg.CoalesceMaxItems,
+		Events:   cfg.CoalesceEvents,
+	}
+}
+
+// buildNotifiers creates and starts every sink enabled in the configuration
+func buildNotifiers(cfg *config.Config, set *templates.Set, tracker *discord.Tracker) (*notifier.Registry, error) {
+	registry := notifier.NewRegistry()
+
+	// Initialize Discord bot if enabled and configured
+	if cfg.EnableDiscord && cfg.DiscordToken != "" && cfg.DiscordChannel != "" {
+		discordBot, err := newDiscordBot(cfg, set, tracker)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(discordBot)
+	} else {
+		log.Println("[DEBUG] Discord integration disabled or not configured")
+		if !cfg.EnableDiscord {
+			log.Println("[DEBUG] - Discord disabled by ENABLE_DISCORD=false")
+		}
+		if cfg.DiscordToken == "" {
+			log.Println("[DEBUG] - Missing DISCORD_TOKEN environment variable")
+		}
+		if cfg.DiscordChannel == "" {
+			log.Println("[DEBUG] - Missing DISCORD_CHANNEL_ID environment variable")
+		}
+	}
+
+	// Initialize Slack sink if enabled
+	if cfg.EnableSlack {
+		slackSink, err := newSlackSink(cfg)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(slackSink)
+	} else {
+		log.Println("[DEBUG] Slack integration disabled")
+	}
+
+	// Initialize Telegram sink if enabled
+	if cfg.EnableTelegram {
+		telegramSink, err := newTelegramSink(cfg)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(telegramSink)
+	} else {
+		log.Println("[DEBUG] Telegram integration disabled")
+	}
+
+	// Digests post through the sinks registered above
+	for _, d := range cfg.Digests {
+		sink, err := newDigestSink(cfg, d, registry)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(sink)
+		sink.Start()
+	}
+
+	log.Printf("[DEBUG] %d notification sink(s) enabled", registry.Len())
+	return registry, nil
+}
+
+// newDigestSink opens a digest; the caller starts its schedule once the
+// sink is registered
+func newDigestSink(cfg *config.Config, d config.Digest, registry *notifier.Registry) (*digest.Sink, error) {
+	log.Printf("[DEBUG] Digest %s is configured, initializing sink...", d.Name)
+	sink, err := digest.Open(cfg.DataDir, digestConfig(d), registry, digest.SystemClock())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open digest: %v", err)
+	}
+	return sink, nil
+}
+
+// digestConfig converts a configured digest to the digest package's settings
+func digestConfig(d config.Digest) digest.Config {
+	return digest.Config{
+		Name:     d.Name,
+		Schedule: d.Schedule,
+		Sink:     d.Sink,
+		Title:    d.Title,
+		Match:    d.Match,
+	}
+}
+
+// newDiscordBot creates the Discord bot and opens its connection
+func newDiscordBot(cfg *config.Config, set *templates.Set, tracker *discord.Tracker) (*discord.Bot, error) {
+	log.Println("[DEBUG] Discord is enabled and configured, initializing bot...")
+	log.Printf("[DEBUG] Discord Channel ID: %s", cfg.DiscordChannel)
+	log.Printf("[DEBUG] Discord Token length: %d characters", len(cfg.DiscordToken))
+
+	discordBot, err := discord.NewBot(cfg.DiscordToken, cfg.DiscordChannel, cfg.RetryPolicy(), discordOptions(cfg, set), tracker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Discord bot: %v", err)
+	}
+	log.Println("[DEBUG] Discord bot instance created successfully")
+
+	log.Println("[DEBUG] Starting Discord bot connection...")
+	if err := discordBot.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start Discord bot: %v", err)
+	}
+	log.Println("Discord bot connected successfully")
+	return discordBot, nil
+}
+
+// discordOptions collects the Discord behaviours that can change without reconnecting
+func discordOptions(cfg *config.Config, set *templates.Set) discord.Options {
+	opts := discord.Options{
+		DMEnabled: cfg.DiscordDMEnabled,
+		DMEvents:  cfg.DiscordDMEvents,
+		DMOptOut:  cfg.DiscordDMOptOut,
+
+		MentionEvents: cfg.DiscordMentionEvents,
+		Routes:        cfg.DiscordRoutes,
+		Templates:     set,
+
+		EditMessages:     cfg.DiscordEditMessages,
+		NewMessageEvents: cfg.DiscordNewMessageEvents,
+
+		IssueThreads: cfg.DiscordIssueThreads,
+
+		Commands:        cfg.DiscordCommandsEnabled,
+		CommandsGuildID: cfg.DiscordCommandsGuildID,
+		CommandRoles:    cfg.DiscordCommandRoles,
+
+		AdminRoles: cfg.DiscordAdminRoles,
+	}
+
+	// Approve and decline buttons need the Overseerr API
+	if cfg.OverseerrURL != "" {
+		client, err := overseerr.NewClient(cfg.OverseerrURL, cfg.OverseerrAPIKey)
+		if err != nil {
+			log.Printf("[WARN] Approval buttons disabled: %v", err)
+		} else {
+			opts.Approver = client
+		}
+	}
+	return opts
+}
+
+// newSlackSink creates the Slack sink
+func newSlackSink(cfg *config.Config) (*slack.Sink, error) {
+	log.Println("[DEBUG] Slack is enabled, initializing sink...")
+	slackSink, err := slack.NewSink(cfg.SlackWebhookURL, cfg.RetryPolicy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Slack sink: %v", err)
+	}
+	return slackSink, nil
+}
+
+// newTelegramSink creates the Telegram sink
+func newTelegramSink(cfg *config.Config) (*telegram.Sink, error) {
+	log.Println("[DEBUG] Telegram is enabled, initializing sink...")
+	telegramSink, err := telegram.NewSink(telegram.Config{
+		Token:   cfg.TelegramToken,
+		ChatID:  cfg.TelegramChatID,
+		APIURL:  cfg.TelegramAPIURL,
+		DMUsers: cfg.TelegramDMUsers,
+	}, cfg.RetryPolicy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Telegram sink: %v", err)
+	}
+	return telegramSink, nil
+}
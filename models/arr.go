@@ -0,0 +1,128 @@
+package models
+
+// SonarrPayload is the JSON posted by Sonarr's webhook connection. Which
+// fields are set depends on EventType.
+type SonarrPayload struct {
+	EventType      string `json:"eventType"`
+	InstanceName   string `json:"instanceName"`
+	ApplicationURL string `json:"applicationUrl"`
+
+	Series      SonarrSeries    `json:"series"`
+	Episodes    []SonarrEpisode `json:"episodes"`
+	EpisodeFile ArrFile         `json:"episodeFile"`
+	ArrDownload
+
+	// Rename
+	RenamedEpisodeFiles []ArrFile `json:"renamedEpisodeFiles"`
+
+	ArrHealth
+	ArrUpdate
+}
+
+// SonarrSeries is the series an event concerns
+type SonarrSeries struct {
+	ID     int        `json:"id"`
+	Title  string     `json:"title"`
+	Year   int        `json:"year"`
+	Path   string     `json:"path"`
+	TvdbID int        `json:"tvdbId"`
+	TmdbID int        `json:"tmdbId"`
+	ImdbID string     `json:"imdbId"`
+	Type   string     `json:"type"`
+	Images []ArrImage `json:"images"`
+}
+
+// SonarrEpisode is one episode of a grab, download or deletion
+type SonarrEpisode struct {
+	ID            int    `json:"id"`
+	SeasonNumber  int    `json:"seasonNumber"`
+	EpisodeNumber int    `json:"episodeNumber"`
+	Title         string `json:"title"`
+	Overview      string `json:"overview"`
+	AirDate       string `json:"airDate"`
+}
+
+// RadarrPayload is the JSON posted by Radarr's webhook connection. Which
+// fields are set depends on EventType.
+type RadarrPayload struct {
+	EventType      string `json:"eventType"`
+	InstanceName   string `json:"instanceName"`
+	ApplicationURL string `json:"applicationUrl"`
+
+	Movie       RadarrMovie `json:"movie"`
+	RemoteMovie RadarrMovie `json:"remoteMovie"`
+	MovieFile   ArrFile     `json:"movieFile"`
+	ArrDownload
+
+	// Rename
+	RenamedMovieFiles []ArrFile `json:"renamedMovieFiles"`
+
+	ArrHealth
+	ArrUpdate
+}
+
+// RadarrMovie is the movie an event concerns
+type RadarrMovie struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Year        int        `json:"year"`
+	ReleaseDate string     `json:"releaseDate"`
+	FolderPath  string     `json:"folderPath"`
+	TmdbID      int        `json:"tmdbId"`
+	ImdbID      string     `json:"imdbId"`
+	Overview    string     `json:"overview"`
+	Images      []ArrImage `json:"images"`
+}
+
+// ArrDownload holds the release and download client of Grab and Download events
+type ArrDownload struct {
+	Release            ArrRelease `json:"release"`
+	IsUpgrade          bool       `json:"isUpgrade"`
+	DownloadClient     string     `json:"downloadClient"`
+	DownloadClientType string     `json:"downloadClientType"`
+	DownloadID         string     `json:"downloadId"`
+}
+
+// ArrRelease is the release grabbed from an indexer
+type ArrRelease struct {
+	Quality        string `json:"quality"`
+	QualityVersion int    `json:"qualityVersion"`
+	ReleaseGroup   string `json:"releaseGroup"`
+	ReleaseTitle   string `json:"releaseTitle"`
+	Indexer        string `json:"indexer"`
+	Size           int64  `json:"size"`
+}
+
+// ArrFile is an imported, renamed or deleted media file
+type ArrFile struct {
+	ID             int    `json:"id"`
+	RelativePath   string `json:"relativePath"`
+	Path           string `json:"path"`
+	Quality        string `json:"quality"`
+	QualityVersion int    `json:"qualityVersion"`
+	ReleaseGroup   string `json:"releaseGroup"`
+	SceneName      string `json:"sceneName"`
+	Size           int64  `json:"size"`
+}
+
+// ArrImage is a poster, fanart or banner; RemoteURL is publicly reachable
+type ArrImage struct {
+	CoverType string `json:"coverType"`
+	URL       string `json:"url"`
+	RemoteURL string `json:"remoteUrl"`
+}
+
+// ArrHealth holds the fields of Health and HealthRestored events;
+// ApplicationUpdate events also fill Message
+type ArrHealth struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	WikiURL string `json:"wikiUrl"`
+}
+
+// ArrUpdate holds the fields of ApplicationUpdate events
+type ArrUpdate struct {
+	PreviousVersion string `json:"previousVersion"`
+	NewVersion      string `json:"newVersion"`
+}
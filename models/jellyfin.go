@@ -0,0 +1,98 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// JellyfinPayload is the JSON posted by the Jellyfin Webhook plugin with
+// its default template. Which fields are set depends on NotificationType.
+type JellyfinPayload struct {
+	NotificationType string `json:"NotificationType"`
+
+	ServerID      string `json:"ServerId"`
+	ServerName    string `json:"ServerName"`
+	ServerVersion string `json:"ServerVersion"`
+	ServerURL     string `json:"ServerUrl"`
+	Timestamp     string `json:"Timestamp"`
+	UtcTimestamp  string `json:"UtcTimestamp"`
+
+	// Item events (ItemAdded, ItemDeleted, Playback*)
+	ItemID        string     `json:"ItemId"`
+	ItemType      string     `json:"ItemType"`
+	Name          string     `json:"Name"`
+	Overview      string     `json:"Overview"`
+	Tagline       string     `json:"Tagline"`
+	Year          FlexString `json:"Year"`
+	SeriesName    string     `json:"SeriesName"`
+	SeasonNumber  FlexString `json:"SeasonNumber"`
+	EpisodeNumber FlexString `json:"EpisodeNumber"`
+	RunTime       string     `json:"RunTime"`
+	ProviderTmdb  string     `json:"Provider_tmdb"`
+	ProviderImdb  string     `json:"Provider_imdb"`
+	ProviderTvdb  string     `json:"Provider_tvdb"`
+
+	// User and session events
+	NotificationUsername string `json:"NotificationUsername"`
+	UserID               string `json:"UserId"`
+	Username             string `json:"Username"`
+	DeviceID             string `json:"DeviceId"`
+	DeviceName           string `json:"DeviceName"`
+	ClientName           string `json:"ClientName"`
+	RemoteEndPoint       string `json:"RemoteEndPoint"`
+
+	// Playback events
+	PlaybackPosition   string     `json:"PlaybackPosition"`
+	PlayedToCompletion FlexString `json:"PlayedToCompletion"`
+	IsPaused           FlexString `json:"IsPaused"`
+
+	// Plugin events
+	PluginName    string `json:"PluginName"`
+	PluginVersion string `json:"PluginVersion"`
+
+	// TaskCompleted
+	TaskName           string `json:"TaskName"`
+	ResultStatus       string `json:"ResultStatus"`
+	ResultErrorMessage string `json:"ResultErrorMessage"`
+}
+
+// FlexString decodes a JSON string, number or boolean as a string; the
+// plugin's templates emit some fields either way
+type FlexString string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *FlexString) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*s = FlexString(value)
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*s = FlexString(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		*s = FlexString(strconv.FormatBool(v))
+	default:
+		*s = FlexString(data)
+	}
+	return nil
+}
+
+// Bool reports whether the value is "true" (case-insensitive)
+func (s FlexString) Bool() bool {
+	value, _ := strconv.ParseBool(string(s))
+	return value
+}
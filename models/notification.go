@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 // Notification represents the webhook payload structure with template-style field names
 type Notification struct {
 	NotificationType string        `json:"notification_type"`
@@ -54,3 +56,34 @@ type Comment struct {
 	CommentedBySettingsDiscordID      string `json:"commentedBy_settings_discordId"`
 	CommentedBySettingsTelegramChatID string `json:"commentedBy_settings_telegramChatId"`
 }
+
+// Detail is a name/value pair carried in Extra, as Overseerr sends them
+type Detail struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Details returns the name/value pairs in Extra, skipping empty values and
+// entries of any other shape
+func (n Notification) Details() []Detail {
+	var details []Detail
+	for _, item := range n.Extra {
+		var detail Detail
+		switch v := item.(type) {
+		case Detail:
+			detail = v
+		case map[string]interface{}:
+			name, _ := v["name"].(string)
+			detail = Detail{Name: name, Value: fmt.Sprint(v["value"])}
+			if v["value"] == nil {
+				detail.Value = ""
+			}
+		default:
+			continue
+		}
+		if detail.Name != "" && detail.Value != "" {
+			details = append(details, detail)
+		}
+	}
+	return details
+}
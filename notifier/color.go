@@ -0,0 +1,48 @@
+package notifier
+
+import "strings"
+
+// ColorForEvent returns the accent colour shared by all sinks for an event
+func ColorForEvent(event string) int {
+	switch strings.ToLower(event) {
+	case "media.available":
+		return 0x00FF00 // Green
+	case "media.requested":
+		return 0x0099FF // Blue
+	case "media.approved":
+		return 0x00FF99 // Teal
+	case "media.declined":
+		return 0xFF0000 // Red
+	case "issue.created":
+		return 0xFF6600 // Orange
+	case "issue.resolved":
+		return 0x00FF00 // Green
+	case "comment.created":
+		return 0x9900FF // Purple
+	case "item.added":
+		return 0x00A4DC // Jellyfin blue
+	case "playback.started", "session.started":
+		return 0x0099FF // Blue
+	case "user.created", "plugin.installed", "plugin.updated":
+		return 0x00FF99 // Teal
+	case "auth.failed", "user.locked_out", "item.deleted", "subtitle.download_failed":
+		return 0xFF0000 // Red
+	case "server.restart_pending":
+		return 0xFF6600 // Orange
+	case "download.grabbed":
+		return 0x0099FF // Blue
+	case "download.completed", "download.upgraded", "library.added", "health.restored":
+		return 0x00FF00 // Green
+	case "application.updated":
+		return 0x00FF99 // Teal
+	case "health.issue", "download.manual_required":
+		return 0xFF6600 // Orange
+	case "library.deleted", "file.deleted":
+		return 0xFF0000 // Red
+	default:
+		if strings.HasPrefix(strings.ToLower(event), "digest.") {
+			return 0x5865F2 // Blurple
+		}
+		return 0x999999 // Gray
+	}
+}
@@ -0,0 +1,306 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"jellynotifier/events"
+)
+
+// Capabilities describes what a sink can render
+type Capabilities struct {
+	// Description is a short human-readable summary of the sink
+	Description string
+	// RichFormatting is true when fields, colours and layout are supported
+	RichFormatting bool
+	// Images is true when poster images are shown
+	Images bool
+	// DirectMessages is true when users can be messaged individually
+	DirectMessages bool
+}
+
+// Notifier is an output destination for notifications
+type Notifier interface {
+	// Name uniquely identifies the sink, e.g. "discord"
+	Name() string
+	// Capabilities describes what the sink supports
+	Capabilities() Capabilities
+	// Send delivers an event, handling retries internally
+	Send(ctx context.Context, event events.Event) error
+}
+
+// Stopper is implemented by sinks holding connections that must be closed
+type Stopper interface {
+	Stop() error
+}
+
+// Renderer is implemented by sinks that can show what they would send
+// without sending it. Implementations must build their output with the same
+// code as Send.
+type Renderer interface {
+	Render(event events.Event) (interface{}, error)
+}
+
+// redeliveryKey marks contexts of deliveries that have been attempted before
+type redeliveryKey struct{}
+
+// WithRedelivery marks ctx as a repeated delivery, such as a dead-letter
+// redrive, so sinks can skip side effects that already happened
+func WithRedelivery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redeliveryKey{}, true)
+}
+
+// IsRedelivery reports whether ctx was marked by WithRedelivery
+func IsRedelivery(ctx context.Context) bool {
+	redelivery, _ := ctx.Value(redeliveryKey{}).(bool)
+	return redelivery
+}
+
+// targetsKey carries the destinations a redelivery is limited to
+type targetsKey struct{}
+
+// WithTargets limits a delivery to the given destinations within a sink,
+// such as the Discord channels that failed before
+func WithTargets(ctx context.Context, targets []string) context.Context {
+	return context.WithValue(ctx, targetsKey{}, targets)
+}
+
+// Targets returns the destinations set by WithTargets, nil when the sink
+// should resolve its own
+func Targets(ctx context.Context) []string {
+	targets, _ := ctx.Value(targetsKey{}).([]string)
+	return targets
+}
+
+// TargetError is returned by sinks posting to several destinations when
+// some of them failed, so only those are retried by a redrive
+type TargetError struct {
+	Targets []string
+	Err     error
+}
+
+// Error returns the underlying error
+func (e *TargetError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes the underlying error to errors.Is and errors.As
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// SendError collects the errors of every sink that failed
+type SendError struct {
+	Errors map[string]error
+}
+
+// Error lists the failing sinks and their errors
+func (e *SendError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, name := range e.Failed() {
+		parts = append(parts, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap exposes the individual sink errors to errors.Is and errors.As
+func (e *SendError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, name := range e.Failed() {
+		errs = append(errs, e.Errors[name])
+	}
+	return errs
+}
+
+// Failed returns the names of the failing sinks in sorted order
+func (e *SendError) Failed() []string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registry holds the enabled sinks and fans notifications out to them
+type Registry struct {
+	mu        sync.RWMutex
+	notifiers []Notifier
+}
+
+// NewRegistry creates a registry with the given sinks
+func NewRegistry(notifiers ...Notifier) *Registry {
+	r := &Registry{}
+	for _, n := range notifiers {
+		r.Register(n)
+	}
+	return r
+}
+
+// Register adds a sink, replacing any sink with the same name
+func (r *Registry) Register(n Notifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	caps := n.Capabilities()
+	log.Printf("[DEBUG] [NOTIFIER] Registering sink %q - %s", n.Name(), caps.Description)
+	for i, existing := range r.notifiers {
+		if existing.Name() == n.Name() {
+			r.notifiers[i] = n
+			return
+		}
+	}
+	r.notifiers = append(r.notifiers, n)
+}
+
+// Unregister removes the named sink and returns it so the caller can stop it
+func (r *Registry) Unregister(name string) (Notifier, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.notifiers {
+		if existing.Name() == name {
+			log.Printf("[DEBUG] [NOTIFIER] Unregistering sink %q", name)
+			r.notifiers = append(r.notifiers[:i], r.notifiers[i+1:]...)
+			return existing, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the named sink
+func (r *Registry) Get(name string) (Notifier, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, n := range r.notifiers {
+		if n.Name() == name {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// Notifiers returns the registered sinks
+func (r *Registry) Notifiers() []Notifier {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Notifier(nil), r.notifiers...)
+}
+
+// Names returns the names of the registered sinks
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.notifiers))
+	for _, n := range r.notifiers {
+		names = append(names, n.Name())
+	}
+	return names
+}
+
+// Len returns the number of registered sinks
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.notifiers)
+}
+
+// Send delivers an event to every registered sink concurrently.
+// It returns a *SendError naming each sink that failed, or nil.
+func (r *Registry) Send(ctx context.Context, event events.Event) error {
+	return r.SendTo(ctx, nil, event)
+}
+
+// SendTo delivers an event to the named sinks concurrently, or to all
+// sinks when names is empty. Unknown names are reported as failures.
+func (r *Registry) SendTo(ctx context.Context, names []string, event events.Event) error {
+	targets := r.Notifiers()
+	errs := make(map[string]error)
+
+	if len(names) > 0 {
+		byName := make(map[string]Notifier, len(targets))
+		for _, n := range targets {
+			byName[n.Name()] = n
+		}
+		targets = targets[:0]
+		for _, name := range names {
+			n, ok := byName[name]
+			if !ok {
+				errs[name] = fmt.Errorf("sink %q is not enabled", name)
+				continue
+			}
+			targets = append(targets, n)
+		}
+	}
+
+	log.Printf("[DEBUG] [NOTIFIER] Fanning out %s to %d sink(s)", event.Kind, len(targets))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, n := range targets {
+		wg.Add(1)
+		go func(n Notifier) {
+			defer wg.Done()
+			if err := n.Send(ctx, event); err != nil {
+				log.Printf("[ERROR] [NOTIFIER] Sink %q failed: %v", n.Name(), err)
+				mu.Lock()
+				errs[n.Name()] = err
+				mu.Unlock()
+				return
+			}
+			log.Printf("[DEBUG] [NOTIFIER] Sink %q delivered %s", n.Name(), event.Kind)
+		}(n)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &SendError{Errors: errs}
+	}
+	return nil
+}
+
+// Render renders the event with every sink that implements Renderer,
+// keyed by sink name. A sink that fails to render maps to its error message.
+func (r *Registry) Render(event events.Event) map[string]interface{} {
+	renderings := make(map[string]interface{})
+	for _, n := range r.Notifiers() {
+		renderer, ok := n.(Renderer)
+		if !ok {
+			continue
+		}
+		rendering, err := renderer.Render(event)
+		if err != nil {
+			log.Printf("[ERROR] [NOTIFIER] Sink %q failed to render %s: %v", n.Name(), event.Kind, err)
+			renderings[n.Name()] = map[string]string{"error": err.Error()}
+			continue
+		}
+		renderings[n.Name()] = rendering
+	}
+	return renderings
+}
+
+// Stop stops every sink that holds a connection
+func (r *Registry) Stop() error {
+	var firstErr error
+	for _, n := range r.Notifiers() {
+		stopper, ok := n.(Stopper)
+		if !ok {
+			continue
+		}
+		log.Printf("[DEBUG] [NOTIFIER] Stopping sink %q...", n.Name())
+		if err := stopper.Stop(); err != nil {
+			log.Printf("[ERROR] [NOTIFIER] Error stopping sink %q: %v", n.Name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,297 @@
+package outbox
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"jellynotifier/events"
+)
+
+// FileName is the name of the outbox log inside the data directory
+const FileName = "outbox.jsonl"
+
+// compactThreshold is the number of acknowledgements after which the log is rewritten
+const compactThreshold = 100
+
+// Entry is an event accepted for delivery but not yet acknowledged
+type Entry struct {
+	ID    string       `json:"id"`
+	Event events.Event `json:"event"`
+	// Sinks restricts delivery to the named sinks; empty means all sinks
+	Sinks []string `json:"sinks,omitempty"`
+	// Targets restricts delivery to destinations within the single sink,
+	// such as Discord channels; empty lets the sink resolve them
+	Targets   []string  `json:"targets,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// record is a single line of the append-only log
+type record struct {
+	Op    string `json:"op"`
+	ID    string `json:"id"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+const (
+	opAdd = "add"
+	opAck = "ack"
+)
+
+// Outbox is a file-backed JSON-lines log of pending notifications.
+// Entries are appended and fsynced before a webhook is acknowledged,
+// and removed by an "ack" record once delivery has completed.
+type Outbox struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	pending map[string]Entry
+	order   []string
+	acked   int
+}
+
+// Open loads the outbox log from dir, creating the directory if needed.
+// Any entries that were never acknowledged are available through Pending.
+func Open(dir string) (*Outbox, error) {
+	log.Printf("[DEBUG] [OUTBOX] Opening outbox in %s", dir)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	o := &Outbox{
+		path:    filepath.Join(dir, FileName),
+		pending: make(map[string]Entry),
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+
+	// Start from a compact log containing only pending entries
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DEBUG] [OUTBOX] Outbox opened with %d pending entries", len(o.order))
+	return o, nil
+}
+
+// load replays the log into memory
+func (o *Outbox) load() error {
+	f, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		log.Println("[DEBUG] [OUTBOX] No existing outbox log found")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening outbox log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn final write after a crash is expected; skip it
+			log.Printf("[WARN] [OUTBOX] Skipping unreadable record on line %d: %v", line, err)
+			continue
+		}
+		switch rec.Op {
+		case opAdd:
+			if rec.Entry == nil {
+				continue
+			}
+			if _, exists := o.pending[rec.ID]; !exists {
+				o.order = append(o.order, rec.ID)
+			}
+			o.pending[rec.ID] = *rec.Entry
+		case opAck:
+			delete(o.pending, rec.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading outbox log: %v", err)
+	}
+
+	o.pruneOrder()
+	log.Printf("[DEBUG] [OUTBOX] Replayed %d records, %d pending", line, len(o.order))
+	return nil
+}
+
+// Append durably records an event for the given sinks (all when empty)
+// and targets within them, and returns its entry
+func (o *Outbox) Append(event events.Event, sinks, targets []string) (Entry, error) {
+	entry := Entry{
+		ID:        newID(),
+		Event:     event,
+		Sinks:     sinks,
+		Targets:   targets,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.write(record{Op: opAdd, ID: entry.ID, Entry: &entry}); err != nil {
+		log.Printf("[ERROR] [OUTBOX] Failed to append entry %s: %v", entry.ID, err)
+		return Entry{}, err
+	}
+	o.pending[entry.ID] = entry
+	o.order = append(o.order, entry.ID)
+
+	log.Printf("[DEBUG] [OUTBOX] Appended entry %s (%d pending)", entry.ID, len(o.pending))
+	return entry, nil
+}
+
+// Ack marks an entry as delivered and compacts the log when worthwhile
+func (o *Outbox) Ack(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.pending[id]; !ok {
+		log.Printf("[DEBUG] [OUTBOX] Ack for unknown entry %s ignored", id)
+		return nil
+	}
+	if err := o.write(record{Op: opAck, ID: id}); err != nil {
+		log.Printf("[ERROR] [OUTBOX] Failed to ack entry %s: %v", id, err)
+		return err
+	}
+	delete(o.pending, id)
+	o.acked++
+	log.Printf("[DEBUG] [OUTBOX] Acknowledged entry %s (%d pending)", id, len(o.pending))
+
+	if len(o.pending) == 0 || o.acked >= compactThreshold {
+		o.pruneOrder()
+		return o.compact()
+	}
+	return nil
+}
+
+// Pending returns the unacknowledged entries in the order they were accepted
+func (o *Outbox) Pending() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]Entry, 0, len(o.pending))
+	for _, id := range o.order {
+		if entry, ok := o.pending[id]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Len returns the number of unacknowledged entries
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Close flushes and closes the log file
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	log.Println("[DEBUG] [OUTBOX] Closing outbox log")
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// write appends a record and fsyncs it. Callers must hold o.mu.
+func (o *Outbox) write(rec record) error {
+	if o.file == nil {
+		return fmt.Errorf("outbox is closed")
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox record: %v", err)
+	}
+	data = append(data, '\n')
+	if _, err := o.file.Write(data); err != nil {
+		return fmt.Errorf("error writing outbox record: %v", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("error syncing outbox log: %v", err)
+	}
+	return nil
+}
+
+// compact rewrites the log with only the pending entries and reopens it for
+// appending. The new log is renamed over the old one so a crash leaves either
+// the old or the new file intact. Callers must hold o.mu (or own o exclusively).
+func (o *Outbox) compact() error {
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating compacted outbox log: %v", err)
+	}
+
+	encoder := json.NewEncoder(tmp)
+	for _, id := range o.order {
+		entry := o.pending[id]
+		if err := encoder.Encode(record{Op: opAdd, ID: id, Entry: &entry}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("error writing compacted outbox log: %v", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing compacted outbox log: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing compacted outbox log: %v", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+	if err := os.Rename(tmpPath, o.path); err != nil {
+		return fmt.Errorf("error replacing outbox log: %v", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error reopening outbox log: %v", err)
+	}
+	o.file = file
+	o.acked = 0
+
+	log.Printf("[DEBUG] [OUTBOX] Compacted outbox log to %d pending entries", len(o.order))
+	return nil
+}
+
+// pruneOrder drops acknowledged IDs from the ordering slice
+func (o *Outbox) pruneOrder() {
+	kept := o.order[:0]
+	for _, id := range o.order {
+		if _, ok := o.pending[id]; ok {
+			kept = append(kept, id)
+		}
+	}
+	o.order = kept
+}
+
+// newID returns a sortable, unique entry identifier
+func newID() string {
+	var buf [6]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%x-%s", time.Now().UnixNano(), hex.EncodeToString(buf[:]))
+}
@@ -0,0 +1,89 @@
+package overseerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"jellynotifier/events"
+	"jellynotifier/models"
+)
+
+// Adapter converts Overseerr (and Jellyseerr) webhook notifications into events
+type Adapter struct{}
+
+// Source implements events.Adapter
+func (Adapter) Source() string {
+	return "overseerr"
+}
+
+// Detect accepts any JSON object: the shared /webhook endpoint has always
+// treated payloads no other source recognises as Overseerr notifications
+func (Adapter) Detect(header http.Header, body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// Decode parses an Overseerr notification into an event
+func (Adapter) Decode(header http.Header, body []byte) ([]events.Event, error) {
+	var notification models.Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("error decoding Overseerr payload: %v", err)
+	}
+	return []events.Event{Event(notification)}, nil
+}
+
+// Event maps an Overseerr notification onto an event. The request's,
+// issue's and comment's authors become actors and Extra becomes attributes.
+func Event(n models.Notification) events.Event {
+	event := events.Event{
+		Source: "overseerr",
+		Kind:   n.Event,
+		Type:   n.NotificationType,
+		Title:  n.Subject,
+		Body:   n.Message,
+		Media: events.Media{
+			Type:     n.Media.MediaType,
+			TmdbID:   n.Media.TmdbId,
+			TvdbID:   n.Media.TvdbId,
+			Status:   n.Media.Status,
+			Status4k: n.Media.Status4k,
+		},
+		RequestID: n.Request.RequestID,
+		Issue: events.Issue{
+			ID:     n.Issue.IssueID,
+			Type:   n.Issue.IssueType,
+			Status: n.Issue.IssueStatus,
+		},
+		Comment: n.Comment.CommentMessage,
+	}
+	event.AddImage(events.ImagePoster, n.Image)
+	event.AddActor(events.Actor{
+		Role:           events.RoleRequester,
+		Name:           n.Request.RequestedByUsername,
+		Email:          n.Request.RequestedByEmail,
+		Avatar:         n.Request.RequestedByAvatar,
+		DiscordID:      n.Request.RequestedBySettingsDiscordID,
+		TelegramChatID: n.Request.RequestedBySettingsTelegramChatID,
+	})
+	event.AddActor(events.Actor{
+		Role:           events.RoleReporter,
+		Name:           n.Issue.ReportedByUsername,
+		Email:          n.Issue.ReportedByEmail,
+		Avatar:         n.Issue.ReportedByAvatar,
+		DiscordID:      n.Issue.ReportedBySettingsDiscordID,
+		TelegramChatID: n.Issue.ReportedBySettingsTelegramChatID,
+	})
+	event.AddActor(events.Actor{
+		Role:           events.RoleCommenter,
+		Name:           n.Comment.CommentedByUsername,
+		Email:          n.Comment.CommentedByEMail,
+		Avatar:         n.Comment.CommentedByAvatar,
+		DiscordID:      n.Comment.CommentedBySettingsDiscordID,
+		TelegramChatID: n.Comment.CommentedBySettingsTelegramChatID,
+	})
+	for _, detail := range n.Details() {
+		event.SetAttr(detail.Name, detail.Value)
+	}
+	return event
+}
@@ -0,0 +1,84 @@
+package overseerr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jellynotifier/retry"
+)
+
+// Client calls the Overseerr (or Jellyseerr) API
+type Client struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewClient creates a client for the Overseerr instance at baseURL,
+// authenticating with its API key
+func NewClient(baseURL, apiKey string) (*Client, error) {
+	log.Println("[DEBUG] [OVERSEERR] Creating new Overseerr client...")
+
+	if baseURL == "" {
+		return nil, fmt.Errorf("overseerr URL is required")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("overseerr API key is required")
+	}
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Overseerr URL %q", baseURL)
+	}
+
+	log.Printf("[DEBUG] [OVERSEERR] API base URL: %s", baseURL)
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Approve approves a pending media request
+func (c *Client) Approve(ctx context.Context, requestID string) error {
+	return c.updateRequest(ctx, requestID, "approve")
+}
+
+// Decline declines a pending media request
+func (c *Client) Decline(ctx context.Context, requestID string) error {
+	return c.updateRequest(ctx, requestID, "decline")
+}
+
+// updateRequest posts a status change for a request. It is not retried:
+// it runs while someone waits on a button click.
+func (c *Client) updateRequest(ctx context.Context, requestID, status string) error {
+	if requestID == "" {
+		return fmt.Errorf("request ID is required")
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/request/%s/%s", c.baseURL, url.PathEscape(requestID), status)
+	log.Printf("[DEBUG] [OVERSEERR] POST %s", endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("error creating Overseerr request: %v", err)
+	}
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Overseerr: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err := retry.CheckHTTPResponse(resp, body); err != nil {
+		return fmt.Errorf("overseerr returned %w", err)
+	}
+	log.Printf("[DEBUG] [OVERSEERR] Request %s: %s done", requestID, status)
+	return nil
+}
@@ -0,0 +1,113 @@
+package overseerr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jellynotifier/retry"
+)
+
+func TestClientUpdatesRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(c *Client) error
+		path   string
+	}{
+		{"approve", func(c *Client) error { return c.Approve(context.Background(), "42") }, "/api/v1/request/42/approve"},
+		{"decline", func(c *Client) error { return c.Decline(context.Background(), "42") }, "/api/v1/request/42/decline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path, apiKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path, apiKey = r.Method, r.URL.Path, r.Header.Get("X-Api-Key")
+				w.Write([]byte(`{"id":42}`))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL+"/", "secret")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			if err := tt.update(client); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if method != http.MethodPost || path != tt.path {
+				t.Errorf("got %s %s, want POST %s", method, path, tt.path)
+			}
+			if apiKey != "secret" {
+				t.Errorf("X-Api-Key = %q, want %q", apiKey, "secret")
+			}
+		})
+	}
+}
+
+func TestClientReturnsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		permanent bool
+	}{
+		{"not found", http.StatusNotFound, true},
+		{"forbidden", http.StatusForbidden, true},
+		{"server error", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"message":"Request not found."}`, tt.status)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "secret")
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			err = client.Approve(context.Background(), "7")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), "Request not found.") {
+				t.Errorf("error %q does not include the response body", err)
+			}
+			if retry.IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent = %t, want %t", retry.IsPermanent(err), tt.permanent)
+			}
+		})
+	}
+}
+
+func TestClientRequiresRequestID(t *testing.T) {
+	client, err := NewClient("http://overseerr.local", "secret")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Decline(context.Background(), ""); err == nil {
+		t.Error("expected an error for an empty request ID")
+	}
+}
+
+func TestNewClientValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		apiKey  string
+	}{
+		{"no URL", "", "secret"},
+		{"no API key", "http://overseerr.local", ""},
+		{"relative URL", "overseerr.local", "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.baseURL, tt.apiKey); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
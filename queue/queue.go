@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"jellynotifier/events"
+)
+
+// Errors returned by Enqueue
+var (
+	ErrFull   = errors.New("queue is full")
+	ErrClosed = errors.New("queue is shut down")
+)
+
+// Job is a single unit of delivery work
+type Job struct {
+	// ID identifies the job's outbox entry
+	ID    string
+	Event events.Event
+	// Sinks restricts delivery to the named sinks; empty means all sinks
+	Sinks []string
+	// Targets restricts delivery to destinations within the sink
+	Targets []string
+	// AcceptedAt is when the webhook was accepted
+	AcceptedAt time.Time
+}
+
+// ProcessFunc delivers a job. Its error is logged by the worker.
+type ProcessFunc func(ctx context.Context, job Job) error
+
+// Queue is a bounded in-process work queue served by a fixed pool of workers
+type Queue struct {
+	jobs    chan Job
+	process ProcessFunc
+	workers int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a queue holding up to size pending jobs and starts the workers
+func New(size, workers int, process ProcessFunc) *Queue {
+	if size < 1 {
+		size = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	log.Printf("[DEBUG] [QUEUE] Creating delivery queue - Size: %d, Workers: %d", size, workers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		jobs:    make(chan Job, size),
+		process: process,
+		workers: workers,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker(i)
+	}
+	return q
+}
+
+// Enqueue adds a job without blocking. It returns ErrFull when the queue
+// is at capacity and ErrClosed once shutdown has started.
+func (q *Queue) Enqueue(job Job) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	select {
+	case q.jobs <- job:
+		log.Printf("[DEBUG] [QUEUE] Job enqueued - Depth: %d/%d", len(q.jobs), cap(q.jobs))
+		return nil
+	default:
+		log.Printf("[WARN] [QUEUE] Queue full (%d jobs), rejecting job", cap(q.jobs))
+		return ErrFull
+	}
+}
+
+// EnqueueWait adds a job, blocking until there is room or ctx is done
+func (q *Queue) EnqueueWait(ctx context.Context, job Job) error {
+	for {
+		err := q.Enqueue(job)
+		if err != ErrFull {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Len returns the number of jobs waiting for a worker
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+// Cap returns the maximum number of waiting jobs
+func (q *Queue) Cap() int {
+	return cap(q.jobs)
+}
+
+// Shutdown stops accepting jobs and waits for the workers to drain the queue.
+// If ctx expires first, in-flight deliveries are cancelled and ctx.Err() is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	log.Printf("[DEBUG] [QUEUE] Draining %d pending jobs...", len(q.jobs))
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		log.Println("[DEBUG] [QUEUE] Queue drained successfully")
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		log.Printf("[ERROR] [QUEUE] Drain interrupted with %d jobs remaining: %v", len(q.jobs), ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// worker processes jobs until the queue is closed and empty
+func (q *Queue) worker(id int) {
+	defer q.wg.Done()
+	log.Printf("[DEBUG] [QUEUE] Worker %d started", id)
+
+	for job := range q.jobs {
+		log.Printf("[DEBUG] [QUEUE] Worker %d processing job %s - Event: %s", id, job.ID, job.Event.Kind)
+		if err := q.process(q.ctx, job); err != nil {
+			log.Printf("[ERROR] [QUEUE] Worker %d failed to process job: %v", id, err)
+		} else {
+			log.Printf("[DEBUG] [QUEUE] Worker %d finished job", id)
+		}
+	}
+
+	log.Printf("[DEBUG] [QUEUE] Worker %d stopped", id)
+}
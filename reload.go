@@ -0,0 +1,286 @@
+package main
+
+import (
+	"log"
+	"reflect"
+	"sync"
+
+	"jellynotifier/config"
+	"jellynotifier/digest"
+	"jellynotifier/discord"
+	"jellynotifier/notifier"
+	"jellynotifier/templates"
+)
+
+// reloader re-reads the configuration and applies sink changes to the
+// registry in place. Queued notifications are unaffected: workers look
+// sinks up in the registry for every delivery.
+type reloader struct {
+	path      string
+	notifiers *notifier.Registry
+	tracker   *discord.Tracker
+	// controller answers the slash commands of a reconnected Discord bot
+	controller discord.Controller
+
+	mu        sync.Mutex
+	cfg       *config.Config
+	templates *templates.Set
+}
+
+// newReloader creates a reloader for the running configuration
+func newReloader(path string, cfg *config.Config, set *templates.Set, notifiers *notifier.Registry, tracker *discord.Tracker, controller discord.Controller) *reloader {
+	return &reloader{path: path, cfg: cfg, templates: set, notifiers: notifiers, tracker: tracker, controller: controller}
+}
+
+// Reload loads and validates the configuration, keeping the current one
+// if the new one is invalid. Sinks are only rebuilt when their own
+// settings changed; the Discord connection is only reopened when its
+// token changed. Every replacement sink is built before any is swapped
+// in, so a sink that fails to build leaves the running sinks and
+// configuration untouched and the next reload retries it.
+func (r *reloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("[DEBUG] [RELOAD] Reloading configuration from %q...", r.path)
+	next, err := config.Load(r.path)
+	if err != nil {
+		log.Printf("[ERROR] [RELOAD] Invalid configuration, keeping the current one: %v", err)
+		return
+	}
+	set, err := templates.Load(next.TemplatesDir)
+	if err != nil {
+		log.Printf("[ERROR] [RELOAD] Invalid templates, keeping the current configuration: %v", err)
+		return
+	}
+
+	warnRestartRequired(r.cfg, next)
+
+	p := &plan{}
+	for _, build := range []func(*config.Config, *templates.Set, *plan) error{
+		r.planDiscord, r.planSlack, r.planTelegram, r.planDigests,
+	} {
+		if err := build(next, set, p); err != nil {
+			log.Printf("[ERROR] [RELOAD] %v; keeping the current configuration", err)
+			p.discard()
+			return
+		}
+	}
+	r.apply(p)
+
+	r.cfg = next
+	r.templates = set
+	log.Printf("Configuration reloaded, %d notification sink(s) enabled", r.notifiers.Len())
+}
+
+// plan collects the sink changes of a reload so they can be built first
+// and applied together
+type plan struct {
+	// sinks are new sinks, replacing any running sink of the same name
+	sinks []notifier.Notifier
+	// updates change running sinks in place
+	updates []func()
+	// removed names the sinks to stop
+	removed []string
+}
+
+// discard stops the sinks built for a plan that is not applied
+func (p *plan) discard() {
+	for _, n := range p.sinks {
+		if stopper, ok := n.(notifier.Stopper); ok {
+			if err := stopper.Stop(); err != nil {
+				log.Printf("[ERROR] [RELOAD] Error stopping unused %q sink: %v", n.Name(), err)
+			}
+		}
+	}
+}
+
+// apply swaps the planned sinks into the registry
+func (r *reloader) apply(p *plan) {
+	for _, n := range p.sinks {
+		old, hadOld := r.notifiers.Get(n.Name())
+		r.replace(n, old, hadOld)
+		if sink, ok := n.(*digest.Sink); ok {
+			// The old digest may have collected more since the new one was opened
+			if previous, ok := old.(*digest.Sink); ok {
+				if err := sink.Adopt(previous); err != nil {
+					log.Printf("[ERROR] [RELOAD] %v", err)
+				}
+			}
+			sink.Start()
+		}
+	}
+	for _, update := range p.updates {
+		update()
+	}
+	for _, name := range p.removed {
+		r.stop(name)
+	}
+}
+
+// planDiscord plans reconnecting, reconfiguring or removing the Discord bot
+func (r *reloader) planDiscord(next *config.Config, set *templates.Set, p *plan) error {
+	prev := r.cfg
+	enabled := next.EnableDiscord && next.DiscordToken != "" && next.DiscordChannel != ""
+	current, running := r.notifiers.Get("discord")
+
+	if !enabled {
+		if running {
+			log.Println("[DEBUG] [RELOAD] Discord disabled, stopping bot")
+			p.removed = append(p.removed, "discord")
+		}
+		return nil
+	}
+
+	bot, isBot := current.(*discord.Bot)
+	if running && isBot && prev.DiscordToken == next.DiscordToken {
+		if prev.DiscordChannel == next.DiscordChannel &&
+			reflect.DeepEqual(discordOptions(prev, r.templates), discordOptions(next, set)) &&
+			prev.RetryPolicy() == next.RetryPolicy() {
+			log.Println("[DEBUG] [RELOAD] Discord settings unchanged")
+			return nil
+		}
+		p.updates = append(p.updates, func() {
+			if err := bot.Reconfigure(next.DiscordChannel, next.RetryPolicy(), discordOptions(next, set)); err != nil {
+				log.Printf("[ERROR] [RELOAD] Failed to reconfigure Discord bot: %v", err)
+				return
+			}
+			log.Println("[DEBUG] [RELOAD] Discord bot reconfigured without reconnecting")
+		})
+		return nil
+	}
+
+	log.Println("[DEBUG] [RELOAD] Discord token changed or bot not running, connecting a new bot")
+	replacement, err := newDiscordBot(next, set, r.tracker)
+	if err != nil {
+		return err
+	}
+	replacement.SetController(r.controller)
+	p.sinks = append(p.sinks, replacement)
+	return nil
+}
+
+// planSlack plans rebuilding or removing the Slack sink when its settings changed
+func (r *reloader) planSlack(next *config.Config, _ *templates.Set, p *plan) error {
+	prev := r.cfg
+	_, running := r.notifiers.Get("slack")
+	if running == next.EnableSlack &&
+		prev.SlackWebhookURL == next.SlackWebhookURL &&
+		prev.RetryPolicy() == next.RetryPolicy() {
+		return nil
+	}
+	if !next.EnableSlack {
+		log.Println("[DEBUG] [RELOAD] Slack disabled, removing sink")
+		p.removed = append(p.removed, "slack")
+		return nil
+	}
+
+	log.Println("[DEBUG] [RELOAD] Slack settings changed, rebuilding sink")
+	sink, err := newSlackSink(next)
+	if err != nil {
+		return err
+	}
+	p.sinks = append(p.sinks, sink)
+	return nil
+}
+
+// planTelegram plans rebuilding or removing the Telegram sink when its settings changed
+func (r *reloader) planTelegram(next *config.Config, _ *templates.Set, p *plan) error {
+	prev := r.cfg
+	_, running := r.notifiers.Get("telegram")
+	if running == next.EnableTelegram &&
+		prev.TelegramToken == next.TelegramToken &&
+		prev.TelegramChatID == next.TelegramChatID &&
+		prev.TelegramAPIURL == next.TelegramAPIURL &&
+		prev.TelegramDMUsers == next.TelegramDMUsers &&
+		prev.RetryPolicy() == next.RetryPolicy() {
+		return nil
+	}
+	if !next.EnableTelegram {
+		log.Println("[DEBUG] [RELOAD] Telegram disabled, removing sink")
+		p.removed = append(p.removed, "telegram")
+		return nil
+	}
+
+	log.Println("[DEBUG] [RELOAD] Telegram settings changed, rebuilding sink")
+	sink, err := newTelegramSink(next)
+	if err != nil {
+		return err
+	}
+	p.sinks = append(p.sinks, sink)
+	return nil
+}
+
+// planDigests plans replacing the digest sinks when their settings changed.
+// Collected notifications stay in the data directory, so a digest that
+// is kept under the same name loses nothing.
+func (r *reloader) planDigests(next *config.Config, _ *templates.Set, p *plan) error {
+	prev := r.cfg
+	if reflect.DeepEqual(prev.Digests, next.Digests) {
+		return nil
+	}
+
+	log.Println("[DEBUG] [RELOAD] Digest settings changed, rebuilding digests")
+	kept := make(map[string]bool, len(next.Digests))
+	for _, d := range next.Digests {
+		sink, err := newDigestSink(next, d, r.notifiers)
+		if err != nil {
+			return err
+		}
+		p.sinks = append(p.sinks, sink)
+		kept[sink.Name()] = true
+	}
+	for _, d := range prev.Digests {
+		if name := digestConfig(d).SinkName(); !kept[name] {
+			p.removed = append(p.removed, name)
+		}
+	}
+	return nil
+}
+
+// replace registers n in place of old and then stops old. Sends already in
+// flight on old finish over REST, which keeps working after its gateway
+// connection is closed.
+func (r *reloader) replace(n notifier.Notifier, old notifier.Notifier, hadOld bool) {
+	r.notifiers.Register(n)
+	if !hadOld {
+		return
+	}
+	if stopper, ok := old.(notifier.Stopper); ok {
+		if err := stopper.Stop(); err != nil {
+			log.Printf("[ERROR] [RELOAD] Error stopping previous %q sink: %v", old.Name(), err)
+		}
+	}
+}
+
+// stop unregisters and stops the named sink
+func (r *reloader) stop(name string) {
+	n, ok := r.notifiers.Unregister(name)
+	if !ok {
+		return
+	}
+	if stopper, ok := n.(notifier.Stopper); ok {
+		if err := stopper.Stop(); err != nil {
+			log.Printf("[ERROR] [RELOAD] Error stopping %q sink: %v", name, err)
+		}
+	}
+}
+
+// warnRestartRequired logs settings that only take effect after a restart
+func warnRestartRequired(prev, next *config.Config) {
+	changed := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			log.Printf("[WARN] [RELOAD] %s changed; restart to apply it", name)
+		}
+	}
+	changed("port", prev.Port, next.Port)
+	changed("data_dir", prev.DataDir, next.DataDir)
+	changed("admin_token", prev.AdminToken, next.AdminToken)
+	changed("shutdown_timeout", prev.ShutdownTimeout, next.ShutdownTimeout)
+	changed("watch_interval", prev.WatchInterval, next.WatchInterval)
+	changed("queue", [2]int{prev.QueueSize, prev.QueueWorkers}, [2]int{next.QueueSize, next.QueueWorkers})
+	changed("coalesce", coalescing(prev), coalescing(next))
+	changed("dedup", []interface{}{prev.DedupWindow, prev.DedupSize, prev.DedupPersist}, []interface{}{next.DedupWindow, next.DedupSize, next.DedupPersist})
+	changed("webhook", []interface{}{prev.WebhookToken, prev.WebhookTokenHeader, prev.WebhookHMACSecret, prev.WebhookSignatureHeader, prev.WebhookTimestampHeader, prev.WebhookMaxSkew},
+		[]interface{}{next.WebhookToken, next.WebhookTokenHeader, next.WebhookHMACSecret, next.WebhookSignatureHeader, next.WebhookTimestampHeader, next.WebhookMaxSkew})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"jellynotifier/config"
+	"jellynotifier/discord"
+	"jellynotifier/events"
+	"jellynotifier/handlers"
+	"jellynotifier/notifier"
+	"jellynotifier/slack"
+	"jellynotifier/telegram"
+	"jellynotifier/templates"
+)
+
+// runRender implements "jellynotifier render [--config FILE] [--source SOURCE] [--event EVENT] PAYLOAD".
+// It prints what every enabled sink would send for the payload (a file, or
+// "-" for stdin) using the same rendering code as delivery, without
+// connecting to any sink. Logs go to stderr, the JSON to stdout.
+func runRender(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv(config.PathEnv), "path to a YAML, JSON or TOML config file (env: "+config.PathEnv+")")
+	source := flags.String("source", "", "the payload's source, e.g. sonarr (default: detected)")
+	kind := flags.String("event", "", "override the payload's event, e.g. media.available")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: jellynotifier render [--config FILE] [--source SOURCE] [--event EVENT] PAYLOAD.json|-")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadLenient(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 1
+	}
+
+	set, err := templates.Load(cfg.TemplatesDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Template error: %v\n", err)
+		return 1
+	}
+
+	event, err := readPayload(flags.Arg(0), *source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid payload: %v\n", err)
+		return 1
+	}
+	if *kind != "" {
+		event.Kind = *kind
+	}
+
+	output, err := json.MarshalIndent(map[string]interface{}{
+		"event": event.Kind,
+		"sinks": buildRenderers(cfg, set).Render(event),
+	}, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding rendering: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(output))
+	return 0
+}
+
+// buildRenderers registers a render-only instance of every enabled sink
+func buildRenderers(cfg *config.Config, set *templates.Set) *notifier.Registry {
+	registry := notifier.NewRegistry()
+	if cfg.EnableDiscord {
+		registry.Register(discord.NewRenderer(cfg.DiscordChannel, discordOptions(cfg, set)))
+	}
+	if cfg.EnableSlack {
+		registry.Register(slack.NewRenderer())
+	}
+	if cfg.EnableTelegram {
+		registry.Register(telegram.NewRenderer(telegram.Config{
+			ChatID:  cfg.TelegramChatID,
+			DMUsers: cfg.TelegramDMUsers,
+		}))
+	}
+	return registry
+}
+
+// readPayload decodes an event from a file, or from stdin for "-", with the
+// named source's adapter or the detected one
+func readPayload(path, source string) (events.Event, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return events.Event{}, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	adapters := handlers.Adapters()
+	decode := adapters.Decode
+	if source != "" {
+		adapter, ok := adapters.Get(source)
+		if !ok {
+			return events.Event{}, fmt.Errorf("unknown source %q, expected one of %s", source, strings.Join(adapters.Sources(), ", "))
+		}
+		decode = adapter.Decode
+	}
+	decoded, err := decode(nil, body)
+	if err != nil {
+		return events.Event{}, err
+	}
+	if len(decoded) == 0 {
+		return events.Event{}, fmt.Errorf("payload holds no events")
+	}
+	return decoded[0], nil
+}
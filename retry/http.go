@@ -0,0 +1,54 @@
+package retry
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CheckHTTPResponse turns an HTTP status into a retry decision for webhook-style
+// APIs: 2xx is success, 429 waits for Retry-After, 5xx is retried and any other
+// 4xx is permanent. body is included in the error to aid debugging.
+func CheckHTTPResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("HTTP %s: %s", resp.Status, truncate(string(body), 200))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return After(err, RetryAfter(resp.Header, time.Second))
+	case resp.StatusCode >= 500:
+		return err
+	case resp.StatusCode >= 400:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+// RetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func RetryAfter(header http.Header, fallback time.Duration) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,163 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Policy describes how failed operations are retried
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// InitialInterval is the base delay before the second attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the exponential delay between attempts
+	MaxInterval time.Duration
+	// MaxElapsed stops retrying once this much time has passed since the first attempt
+	MaxElapsed time.Duration
+	// Multiplier grows the delay after every attempt
+	Multiplier float64
+}
+
+// DefaultPolicy returns the policy used when nothing is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     5,
+		InitialInterval: 1 * time.Second,
+		MaxInterval:     30 * time.Second,
+		MaxElapsed:      2 * time.Minute,
+		Multiplier:      2,
+	}
+}
+
+// Error is returned by Do when the operation did not succeed
+type Error struct {
+	Attempts  int
+	Elapsed   time.Duration
+	Permanent bool
+	Err       error
+}
+
+// Error returns the last error together with the attempt count
+func (e *Error) Error() string {
+	if e.Permanent {
+		return fmt.Sprintf("permanent failure after %d attempt(s): %v", e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("gave up after %d attempt(s) in %s: %v", e.Attempts, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+// Unwrap returns the last error returned by the operation
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Do stops retrying immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// afterError carries a server-provided delay such as Retry-After
+type afterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *afterError) Error() string { return e.err.Error() }
+func (e *afterError) Unwrap() error { return e.err }
+
+// After wraps err so that the next attempt waits at least delay
+func After(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &afterError{err: err, delay: delay}
+}
+
+// Do runs op until it succeeds, returns a permanent error, or the policy is
+// exhausted. op receives the 1-based attempt number. Failures are returned as *Error.
+func Do(ctx context.Context, p Policy, op func(attempt int) error) error {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+
+	start := time.Now()
+	interval := p.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		err := op(attempt)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("[DEBUG] [RETRY] Operation succeeded on attempt %d", attempt)
+			}
+			return nil
+		}
+
+		elapsed := time.Since(start)
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			log.Printf("[ERROR] [RETRY] Permanent failure on attempt %d: %v", attempt, perm.err)
+			return &Error{Attempts: attempt, Elapsed: elapsed, Permanent: true, Err: perm.err}
+		}
+		if attempt >= p.MaxAttempts {
+			log.Printf("[ERROR] [RETRY] Giving up after %d attempts: %v", attempt, err)
+			return &Error{Attempts: attempt, Elapsed: elapsed, Err: err}
+		}
+
+		delay := jitter(interval)
+		var after *afterError
+		if errors.As(err, &after) && after.delay > delay {
+			delay = after.delay
+		}
+		if p.MaxElapsed > 0 && elapsed+delay > p.MaxElapsed {
+			log.Printf("[ERROR] [RETRY] Giving up after %d attempts, next delay %s exceeds max elapsed %s: %v", attempt, delay, p.MaxElapsed, err)
+			return &Error{Attempts: attempt, Elapsed: elapsed, Err: err}
+		}
+
+		log.Printf("[WARN] [RETRY] Attempt %d failed, retrying in %s: %v", attempt, delay.Round(time.Millisecond), err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &Error{Attempts: attempt, Elapsed: time.Since(start), Err: fmt.Errorf("%v (retry aborted: %v)", err, ctx.Err())}
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * p.Multiplier)
+		if p.MaxInterval > 0 && interval > p.MaxInterval {
+			interval = p.MaxInterval
+		}
+	}
+}
+
+// jitter returns a random delay between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
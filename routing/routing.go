@@ -0,0 +1,162 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"jellynotifier/events"
+)
+
+// Mode selects how matching rules are combined
+type Mode string
+
+const (
+	// FirstMatch routes to the channels of the first matching rule
+	FirstMatch Mode = "first-match"
+	// FanOut routes to the channels of every matching rule
+	FanOut Mode = "fan-out"
+)
+
+// Match selects events. Every non-empty list must contain the event's
+// value; values are compared case-insensitively and may use
+// shell-style wildcards such as "media.*". Empty lists match anything.
+type Match struct {
+	// Source matches the adapter, e.g. "overseerr" or "sonarr"
+	Source []string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Event matches the event kind, e.g. "media.available"
+	Event []string `json:"event,omitempty" yaml:"event,omitempty"`
+	// NotificationType matches the source's own event name, e.g. "MEDIA_AVAILABLE"
+	NotificationType []string `json:"notification_type,omitempty" yaml:"notification_type,omitempty"`
+	MediaType        []string `json:"media_type,omitempty" yaml:"media_type,omitempty"`
+	Status4k         []string `json:"status4k,omitempty" yaml:"status4k,omitempty"`
+	// Requester matches the requester's username or Discord ID
+	Requester []string `json:"requester,omitempty" yaml:"requester,omitempty"`
+	IssueType []string `json:"issue_type,omitempty" yaml:"issue_type,omitempty"`
+}
+
+// Rule maps a matcher to one or more destination channels
+type Rule struct {
+	Name     string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Match    Match    `json:"match" yaml:"match"`
+	Channels []string `json:"channels" yaml:"channels"`
+}
+
+// Table is an ordered set of routing rules with a default route
+type Table struct {
+	Mode  Mode   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Rules []Rule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Default receives events no rule matched
+	Default []string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// Parse decodes a routing table from JSON and validates it
+func Parse(data string) (Table, error) {
+	var table Table
+	if strings.TrimSpace(data) == "" {
+		return table, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&table); err != nil {
+		return Table{}, fmt.Errorf("error parsing routing table: %v", err)
+	}
+	if err := table.Validate(); err != nil {
+		return Table{}, err
+	}
+	return table, nil
+}
+
+// Validate checks the mode, that every rule has channels and that patterns compile
+func (t Table) Validate() error {
+	switch t.Mode {
+	case "", FirstMatch, FanOut:
+	default:
+		return fmt.Errorf("routing mode must be %q or %q, got %q", FirstMatch, FanOut, t.Mode)
+	}
+
+	for i, rule := range t.Rules {
+		if len(rule.Channels) == 0 {
+			return fmt.Errorf("routing rule %d (%s) has no channels", i, rule.Name)
+		}
+		if err := rule.Match.Validate(); err != nil {
+			return fmt.Errorf("routing rule %d (%s) %v", i, rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that every pattern compiles
+func (m Match) Validate() error {
+	for _, patterns := range m.lists() {
+		for _, pattern := range patterns {
+			if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+				return fmt.Errorf("has invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve returns the channels an event should be posted to, falling back
+// to the table's default route when no rule matches
+func (t Table) Resolve(event events.Event) []string {
+	var channels []string
+	seen := make(map[string]bool)
+
+	for i, rule := range t.Rules {
+		if !rule.Match.Matches(event) {
+			continue
+		}
+		log.Printf("[DEBUG] [ROUTING] Rule %d (%s) matched %s", i, rule.Name, event.Kind)
+		for _, channel := range rule.Channels {
+			if !seen[channel] {
+				seen[channel] = true
+				channels = append(channels, channel)
+			}
+		}
+		if t.Mode != FanOut {
+			break
+		}
+	}
+
+	if len(channels) == 0 {
+		log.Printf("[DEBUG] [ROUTING] No rule matched %s, using default route", event.Kind)
+		return t.Default
+	}
+	return channels
+}
+
+// Matches reports whether the event satisfies every matcher
+func (m Match) Matches(event events.Event) bool {
+	requester := event.Requester()
+	return matchAny(m.Source, event.Source) &&
+		matchAny(m.Event, event.Kind) &&
+		matchAny(m.NotificationType, event.Type) &&
+		matchAny(m.MediaType, event.Media.Type) &&
+		matchAny(m.Status4k, event.Media.Status4k) &&
+		matchAny(m.IssueType, event.Issue.Type) &&
+		(matchAny(m.Requester, requester.Name) ||
+			matchAny(m.Requester, requester.DiscordID))
+}
+
+// lists returns every pattern list of the matcher
+func (m Match) lists() [][]string {
+	return [][]string{m.Source, m.Event, m.NotificationType, m.MediaType, m.Status4k, m.Requester, m.IssueType}
+}
+
+// matchAny reports whether value matches one of the patterns; no patterns match everything
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
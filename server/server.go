@@ -2,27 +2,38 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"jellynotifier/auth"
 	"jellynotifier/handlers"
 )
 
 // Server represents the HTTP server configuration
 type Server struct {
-	Port       string
+	Port string
+	// ShutdownTimeout bounds the HTTP shutdown plus queue drain (default 15s)
+	ShutdownTimeout time.Duration
+
 	httpServer *http.Server
 	handler    *handlers.Handler
+	verifier   *auth.Verifier
+	adminAuth  *auth.Verifier
 }
 
-// New creates a new server instance with the provided webhook handler
-func New(port string, webhookHandler *handlers.Handler) *Server {
+// New creates a new server instance with the provided webhook handler.
+// A nil verifier leaves the webhook endpoint unauthenticated; the admin
+// endpoints are only registered when adminAuth is configured.
+func New(port string, webhookHandler *handlers.Handler, verifier, adminAuth *auth.Verifier) *Server {
 	log.Printf("[DEBUG] [SERVER] Creating new server instance on port %s", port)
 	return &Server{
-		Port:    port,
-		handler: webhookHandler,
+		Port:      port,
+		handler:   webhookHandler,
+		verifier:  verifier,
+		adminAuth: adminAuth,
 	}
 }
 
@@ -33,12 +44,19 @@ func (s *Server) SetupRoutes() {
 
 	if s.handler != nil {
 		log.Println("[DEBUG] [SERVER] Using new handler methods")
-		mux.HandleFunc("/webhook", s.handler.HandleWebhook)
+		mux.HandleFunc("/webhook", s.verifier.Middleware(s.handler.HandleWebhook))
+		mux.HandleFunc("/webhook/{source}", s.verifier.Middleware(s.handler.HandleSourceWebhook))
 		mux.HandleFunc("/health", s.handler.HealthHandler)
 		mux.HandleFunc("/test", s.handler.TestHandler)
+
+		if s.adminAuth.Enabled() {
+			s.setupAdminRoutes(mux)
+		} else {
+			log.Println("[DEBUG] [SERVER] ADMIN_TOKEN not set, admin endpoints disabled")
+		}
 	} else {
 		log.Println("[DEBUG] [SERVER] Using legacy handlers for backward compatibility")
-		mux.HandleFunc("/webhook", handlers.WebhookHandler)
+		mux.HandleFunc("/webhook", s.verifier.Middleware(handlers.WebhookHandler))
 		mux.HandleFunc("/health", handlers.HealthHandler)
 		mux.HandleFunc("/test", handlers.TestHandler)
 	}
@@ -49,7 +67,22 @@ func (s *Server) SetupRoutes() {
 	}
 
 	log.Printf("[DEBUG] [SERVER] HTTP server configured on address %s", s.httpServer.Addr)
-	log.Println("[DEBUG] [SERVER] Registered routes: /webhook, /health, /test")
+	log.Println("[DEBUG] [SERVER] Registered routes: /webhook, /webhook/{source}, /health, /test")
+}
+
+// setupAdminRoutes registers the token-protected admin endpoints
+func (s *Server) setupAdminRoutes(mux *http.ServeMux) {
+	admin := s.adminAuth.Middleware
+
+	mux.HandleFunc("GET /admin/deadletters", admin(s.handler.ListDeadLetters))
+	mux.HandleFunc("DELETE /admin/deadletters", admin(s.handler.PurgeDeadLetters))
+	mux.HandleFunc("POST /admin/deadletters/redrive", admin(s.handler.RedriveDeadLetters))
+	mux.HandleFunc("GET /admin/deadletters/{id}", admin(s.handler.GetDeadLetter))
+	mux.HandleFunc("DELETE /admin/deadletters/{id}", admin(s.handler.DeleteDeadLetter))
+	mux.HandleFunc("POST /admin/deadletters/{id}/redrive", admin(s.handler.RedriveDeadLetter))
+	mux.HandleFunc("POST /admin/render", admin(s.handler.RenderNotification))
+
+	log.Println("[DEBUG] [SERVER] Registered admin routes: /admin/deadletters, /admin/render")
 }
 
 // Start starts the HTTP server on the configured port
@@ -60,29 +93,51 @@ func (s *Server) Start() error {
 	fmt.Printf("Server starting on port %s...\n", s.Port)
 
 	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		// Returned immediately by Shutdown; draining continues there
+		log.Println("[DEBUG] [SERVER] HTTP server stopped accepting connections")
+		return nil
+	}
 	if err != nil {
 		log.Printf("[ERROR] [SERVER] Server failed to start: %v", err)
 	}
 	return err
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server and drains the delivery queue
 func (s *Server) Shutdown() error {
-	if s.httpServer == nil {
-		log.Println("[DEBUG] [SERVER] No HTTP server to shutdown")
-		return nil
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second // Increased from 5s to 15s
 	}
 
-	log.Println("[DEBUG] [SERVER] Initiating graceful server shutdown...")
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second) // Increased from 5s to 15s
+	log.Printf("[DEBUG] [SERVER] Initiating graceful server shutdown (timeout: %s)...", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	fmt.Println("Shutting down server...")
-	err := s.httpServer.Shutdown(ctx)
-	if err != nil {
-		log.Printf("[ERROR] [SERVER] Error during server shutdown: %v", err)
+	var err error
+	if s.httpServer == nil {
+		log.Println("[DEBUG] [SERVER] No HTTP server to shutdown")
 	} else {
-		log.Println("[DEBUG] [SERVER] Server shutdown completed successfully")
+		fmt.Println("Shutting down server...")
+		err = s.httpServer.Shutdown(ctx)
+		if err != nil {
+			log.Printf("[ERROR] [SERVER] Error during server shutdown: %v", err)
+		} else {
+			log.Println("[DEBUG] [SERVER] Server shutdown completed successfully")
+		}
+	}
+
+	// Drain queued notifications once no new webhooks can arrive
+	if s.handler != nil {
+		if drainErr := s.handler.Shutdown(ctx); drainErr != nil {
+			log.Printf("[ERROR] [SERVER] Error draining delivery queue: %v", drainErr)
+			if err == nil {
+				err = drainErr
+			}
+		} else {
+			log.Println("[DEBUG] [SERVER] Delivery queue drained successfully")
+		}
 	}
 	return err
 }
@@ -0,0 +1,306 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+	"jellynotifier/retry"
+)
+
+// Block Kit limits enforced when building messages
+const (
+	maxHeaderLength = 150
+	maxTextLength   = 3000
+	maxFieldLength  = 2000
+)
+
+// Sink posts notifications to a Slack incoming webhook using Block Kit
+type Sink struct {
+	webhookURL string
+	client     *http.Client
+	policy     retry.Policy
+}
+
+// NewSink creates a Slack sink posting to webhookURL, retrying according to policy
+func NewSink(webhookURL string, policy retry.Policy) (*Sink, error) {
+	log.Println("[DEBUG] [SLACK] Creating new Slack sink...")
+
+	if webhookURL == "" {
+		log.Println("[ERROR] [SLACK] Slack webhook URL is empty")
+		return nil, fmt.Errorf("slack webhook URL is required")
+	}
+
+	log.Printf("[DEBUG] [SLACK] Retry policy - Max attempts: %d, Max elapsed: %s", policy.MaxAttempts, policy.MaxElapsed)
+	return &Sink{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 15 * time.Second},
+		policy:     policy,
+	}, nil
+}
+
+// Name identifies the Slack sink
+func (s *Sink) Name() string {
+	return "slack"
+}
+
+// Capabilities describes the Slack sink
+func (s *Sink) Capabilities() notifier.Capabilities {
+	return notifier.Capabilities{
+		Description:    "Slack incoming webhook posting Block Kit messages",
+		RichFormatting: true,
+		Images:         true,
+	}
+}
+
+// Send implements notifier.Notifier, retrying transient failures and rate limits
+func (s *Sink) Send(ctx context.Context, event events.Event) error {
+	log.Printf("[DEBUG] [SLACK] Preparing to send notification - Type: %s, Event: %s", event.Type, event.Kind)
+
+	payload, err := json.Marshal(s.buildMessage(event))
+	if err != nil {
+		return fmt.Errorf("error encoding Slack message: %v", err)
+	}
+
+	err = retry.Do(ctx, s.policy, func(attempt int) error {
+		log.Printf("[DEBUG] [SLACK] Posting message (attempt %d)", attempt)
+		return s.post(ctx, payload)
+	})
+	if err != nil {
+		log.Printf("[ERROR] [SLACK] Failed to send message: %v", err)
+		return fmt.Errorf("error sending message to Slack: %w", err)
+	}
+
+	log.Println("Successfully sent notification to Slack")
+	return nil
+}
+
+// NewRenderer creates a Sink without a webhook URL. It can Render
+// notifications for previews but must not be used to Send them.
+func NewRenderer() *Sink {
+	return &Sink{}
+}
+
+// Render implements notifier.Renderer, returning the Block Kit message Send
+// would post
+func (s *Sink) Render(event events.Event) (interface{}, error) {
+	return s.buildMessage(event), nil
+}
+
+// post performs a single webhook request
+func (s *Sink) post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("error creating Slack request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return retry.CheckHTTPResponse(resp, body)
+}
+
+// Message is the JSON body accepted by Slack incoming webhooks
+type Message struct {
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment wraps blocks so they get a coloured side bar
+type Attachment struct {
+	Color  string  `json:"color,omitempty"`
+	Blocks []Block `json:"blocks"`
+}
+
+// Block is a Block Kit layout block
+type Block struct {
+	Type      string    `json:"type"`
+	Text      *Text     `json:"text,omitempty"`
+	Fields    []Text    `json:"fields,omitempty"`
+	Accessory *Element  `json:"accessory,omitempty"`
+	Elements  []Element `json:"elements,omitempty"`
+}
+
+// Text is a Block Kit text object
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Element is a Block Kit image or context element
+type Element struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// buildMessage renders the same information as the Discord embed as Block Kit
+func (s *Sink) buildMessage(event events.Event) Message {
+	log.Println("[DEBUG] [SLACK] Creating Slack message...")
+
+	var blocks []Block
+
+	if event.Title != "" {
+		blocks = append(blocks, Block{
+			Type: "header",
+			Text: &Text{Type: "plain_text", Text: truncate(event.Title, maxHeaderLength)},
+		})
+	}
+
+	// Message with the poster as accessory image
+	if event.Body != "" || event.Image() != "" {
+		section := Block{
+			Type: "section",
+			Text: &Text{Type: "mrkdwn", Text: truncate(escape(event.Body), maxTextLength)},
+		}
+		if section.Text.Text == "" {
+			section.Text.Text = " "
+		}
+		if event.Image() != "" {
+			section.Accessory = &Element{Type: "image", ImageURL: event.Image(), AltText: "poster"}
+		}
+		blocks = append(blocks, section)
+	}
+
+	// Notification type and event info
+	var summary []Text
+	if event.Type != "" {
+		summary = append(summary, field("📋 Type", event.Type))
+	}
+	if event.Kind != "" {
+		summary = append(summary, field("🎬 Event", event.Kind))
+	}
+	if len(summary) > 0 {
+		blocks = append(blocks, Block{Type: "section", Fields: summary})
+	}
+
+	// Media information
+	if event.Media.Type != "" {
+		mediaInfo := []string{fmt.Sprintf("Type: %s", event.Media.Type)}
+		if event.Media.Status != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("Status: %s", event.Media.Status))
+		}
+		if event.Media.Status4k != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("4K Status: %s", event.Media.Status4k))
+		}
+		if event.Media.TmdbID != "" {
+			mediaInfo = append(mediaInfo, fmt.Sprintf("TMDB: %s", event.Media.TmdbID))
+		}
+		blocks = append(blocks, infoBlock("🎭 Media Info", mediaInfo))
+	}
+
+	// Request information
+	if event.RequestID != "" {
+		requestInfo := []string{fmt.Sprintf("ID: %s", event.RequestID)}
+		if event.Requester().Name != "" {
+			requestInfo = append(requestInfo, fmt.Sprintf("Requested by: %s", event.Requester().Name))
+		}
+		if event.Requester().Email != "" {
+			requestInfo = append(requestInfo, fmt.Sprintf("Email: %s", event.Requester().Email))
+		}
+		blocks = append(blocks, infoBlock("📝 Request Info", requestInfo))
+	}
+
+	// Issue information
+	if event.Issue.ID != "" {
+		issueInfo := []string{fmt.Sprintf("ID: %s", event.Issue.ID)}
+		if event.Issue.Type != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Type: %s", event.Issue.Type))
+		}
+		if event.Issue.Status != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Status: %s", event.Issue.Status))
+		}
+		if event.Reporter().Name != "" {
+			issueInfo = append(issueInfo, fmt.Sprintf("Reported by: %s", event.Reporter().Name))
+		}
+		blocks = append(blocks, infoBlock("🐛 Issue Info", issueInfo))
+	}
+
+	// Comment information
+	if event.Comment != "" {
+		commentInfo := []string{fmt.Sprintf("Message: %s", event.Comment)}
+		if event.Commenter().Name != "" {
+			commentInfo = append(commentInfo, fmt.Sprintf("By: %s", event.Commenter().Name))
+		}
+		blocks = append(blocks, infoBlock("💬 Comment", commentInfo))
+	}
+
+	// Extra details, at most ten fields per section
+	var details []Text
+	for _, detail := range event.Attributes {
+		if len(details) == 10 {
+			break
+		}
+		details = append(details, field(detail.Name, detail.Value))
+	}
+	if len(details) > 0 {
+		blocks = append(blocks, Block{Type: "section", Fields: details})
+	}
+
+	blocks = append(blocks, Block{
+		Type: "context",
+		Elements: []Element{{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", time.Now().Unix(), time.Now().Format(time.RFC3339)),
+		}},
+	})
+
+	fallback := event.Title
+	if fallback == "" {
+		fallback = event.Kind
+	}
+
+	log.Printf("[DEBUG] [SLACK] Message creation completed with %d blocks", len(blocks))
+	return Message{
+		Text: fallback,
+		Attachments: []Attachment{{
+			Color:  fmt.Sprintf("#%06X", notifier.ColorForEvent(event.Kind)),
+			Blocks: blocks,
+		}},
+	}
+}
+
+// infoBlock renders a titled list of lines as a section block
+func infoBlock(title string, lines []string) Block {
+	for i, line := range lines {
+		lines[i] = escape(line)
+	}
+	text := fmt.Sprintf("*%s*\n%s", title, strings.Join(lines, "\n"))
+	return Block{
+		Type: "section",
+		Text: &Text{Type: "mrkdwn", Text: truncate(text, maxTextLength)},
+	}
+}
+
+// field renders a bold label and value as a section field
+func field(label, value string) Text {
+	return Text{Type: "mrkdwn", Text: truncate(fmt.Sprintf("*%s*\n%s", label, escape(value)), maxFieldLength)}
+}
+
+// escape encodes the characters Slack treats as control sequences in mrkdwn
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
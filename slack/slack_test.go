@@ -0,0 +1,250 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+	"jellynotifier/retry"
+)
+
+// webhook is a stand-in Slack webhook answering with the given statuses in
+// turn, then 200
+type webhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   [][]byte
+}
+
+func newWebhook(t *testing.T, header http.Header, statuses ...int) *webhook {
+	w := &webhook{statuses: statuses, header: header}
+	w.Server = httptest.NewServer(http.HandlerFunc(w.serve))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *webhook) serve(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(rw, "invalid_payload", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.bodies = append(w.bodies, body)
+
+	status := http.StatusOK
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	if status != http.StatusOK {
+		for name, values := range w.header {
+			rw.Header()[name] = values
+		}
+		http.Error(rw, fmt.Sprintf("error %d", status), status)
+		return
+	}
+	rw.Write([]byte("ok"))
+}
+
+func (w *webhook) requests() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.bodies)
+}
+
+// fastPolicy retries quickly so tests do not wait on backoff
+var fastPolicy = retry.Policy{
+	MaxAttempts:     3,
+	InitialInterval: time.Millisecond,
+	MaxInterval:     time.Millisecond,
+	MaxElapsed:      10 * time.Second,
+	Multiplier:      1,
+}
+
+func TestSendPostsBlockKit(t *testing.T) {
+	hook := newWebhook(t, nil)
+	sink, err := NewSink(hook.URL, fastPolicy)
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+
+	event := events.Event{
+		Source:    "overseerr",
+		Kind:      "media.available",
+		Type:      "MEDIA_AVAILABLE",
+		Title:     "Dune is available",
+		Body:      "Tom & Jerry <3",
+		Media:     events.Media{Type: "movie", Status: "AVAILABLE", TmdbID: "438631"},
+		RequestID: "42",
+	}
+	event.AddImage(events.ImagePoster, "https://image.example/poster.jpg")
+	event.AddActor(events.Actor{Role: events.RoleRequester, Name: "alice"})
+	event.SetAttr("Quality", "4K")
+
+	if err := sink.Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if hook.requests() != 1 {
+		t.Fatalf("webhook got %d request(s), want 1", hook.requests())
+	}
+
+	var message Message
+	if err := json.Unmarshal(hook.bodies[0], &message); err != nil {
+		t.Fatalf("payload is not a Slack message: %v\n%s", err, hook.bodies[0])
+	}
+	if message.Text != "Dune is available" {
+		t.Errorf("fallback text = %q", message.Text)
+	}
+	if len(message.Attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(message.Attachments))
+	}
+	attachment := message.Attachments[0]
+	if want := fmt.Sprintf("#%06X", notifier.ColorForEvent("media.available")); attachment.Color != want {
+		t.Errorf("color = %q, want %q", attachment.Color, want)
+	}
+
+	blocks := attachment.Blocks
+	types := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		types = append(types, block.Type)
+	}
+	wantTypes := "header section section section section section context"
+	if strings.Join(types, " ") != wantTypes {
+		t.Fatalf("block types = %v, want %s", types, wantTypes)
+	}
+
+	if header := blocks[0].Text; header.Type != "plain_text" || header.Text != "Dune is available" {
+		t.Errorf("header = %+v", header)
+	}
+	body := blocks[1]
+	if body.Text.Text != "Tom &amp; Jerry &lt;3" {
+		t.Errorf("body text = %q, want it escaped", body.Text.Text)
+	}
+	if body.Accessory == nil || body.Accessory.Type != "image" || body.Accessory.ImageURL != "https://image.example/poster.jpg" {
+		t.Errorf("body accessory = %+v, want the poster", body.Accessory)
+	}
+	if fields := blocks[2].Fields; len(fields) != 2 || fields[0].Text != "*📋 Type*\nMEDIA_AVAILABLE" || fields[1].Text != "*🎬 Event*\nmedia.available" {
+		t.Errorf("summary fields = %+v", fields)
+	}
+	if text := blocks[3].Text.Text; text != "*🎭 Media Info*\nType: movie\nStatus: AVAILABLE\nTMDB: 438631" {
+		t.Errorf("media info = %q", text)
+	}
+	if text := blocks[4].Text.Text; text != "*📝 Request Info*\nID: 42\nRequested by: alice" {
+		t.Errorf("request info = %q", text)
+	}
+	if fields := blocks[5].Fields; len(fields) != 1 || fields[0].Text != "*Quality*\n4K" {
+		t.Errorf("attribute fields = %+v", fields)
+	}
+}
+
+func TestBuildMessageTruncatesHeader(t *testing.T) {
+	message := (&Sink{}).buildMessage(events.Event{Kind: "media.available", Title: strings.Repeat("a", 200)})
+	header := message.Attachments[0].Blocks[0].Text.Text
+	if n := len([]rune(header)); n != maxHeaderLength {
+		t.Errorf("header is %d runes, want %d", n, maxHeaderLength)
+	}
+}
+
+func TestPostClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			hook := newWebhook(t, nil, tt.status)
+			sink, err := NewSink(hook.URL, fastPolicy)
+			if err != nil {
+				t.Fatalf("NewSink: %v", err)
+			}
+			err = sink.post(context.Background(), []byte(`{"text":"hi"}`))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if retry.IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent = %t, want %t (%v)", retry.IsPermanent(err), tt.permanent, err)
+			}
+		})
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		header    http.Header
+		requests  int
+		wantErr   bool
+		permanent bool
+		minWait   time.Duration
+	}{
+		{name: "server error then success", statuses: []int{500, 502}, requests: 3},
+		{name: "rate limited honours Retry-After", statuses: []int{429}, header: http.Header{"Retry-After": {"0.2"}}, requests: 2, minWait: 200 * time.Millisecond},
+		{name: "server errors exhaust the policy", statuses: []int{500, 500, 500}, requests: 3, wantErr: true},
+		{name: "client error is not retried", statuses: []int{400}, requests: 1, wantErr: true, permanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := newWebhook(t, tt.header, tt.statuses...)
+			sink, err := NewSink(hook.URL, fastPolicy)
+			if err != nil {
+				t.Fatalf("NewSink: %v", err)
+			}
+
+			started := time.Now()
+			err = sink.Send(context.Background(), events.Event{Kind: "media.available", Title: "Dune"})
+			if elapsed := time.Since(started); elapsed < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", elapsed, tt.minWait)
+			}
+			if hook.requests() != tt.requests {
+				t.Errorf("webhook got %d request(s), want %d", hook.requests(), tt.requests)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var retryErr *retry.Error
+			if !errors.As(err, &retryErr) {
+				t.Fatalf("error = %v, want a *retry.Error", err)
+			}
+			if retryErr.Permanent != tt.permanent || retryErr.Attempts != tt.requests {
+				t.Errorf("got %d attempt(s), permanent %t; want %d, %t", retryErr.Attempts, retryErr.Permanent, tt.requests, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestNewSinkRequiresURL(t *testing.T) {
+	if _, err := NewSink("", fastPolicy); err == nil {
+		t.Error("expected an error for an empty webhook URL")
+	}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Map is a small string-keyed map persisted as a single JSON file.
+// Every mutation rewrites the file atomically, so it suits state that
+// changes at notification rate, not at request rate.
+type Map[V any] struct {
+	mu    sync.RWMutex
+	path  string
+	items map[string]V
+}
+
+// Open loads the map stored at path, creating its directory if needed
+func Open[V any](path string) (*Map[V], error) {
+	log.Printf("[DEBUG] [STORE] Opening %s", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %v", err)
+	}
+
+	m := &Map[V]{
+		path:  path,
+		items: make(map[string]V),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("[DEBUG] [STORE] %s does not exist yet, starting empty", path)
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &m.items); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", path, err)
+		}
+	}
+
+	log.Printf("[DEBUG] [STORE] Loaded %d items from %s", len(m.items), path)
+	return m, nil
+}
+
+// Get returns the value stored under key
+func (m *Map[V]) Get(key string) (V, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.items[key]
+	return value, ok
+}
+
+// Put stores value under key and persists the map
+func (m *Map[V]) Put(key string, value V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = value
+	return m.save()
+}
+
+// Update applies fn to the current value (zero if absent) and persists the result
+func (m *Map[V]) Update(key string, fn func(value V, exists bool) V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.items[key]
+	m.items[key] = fn(value, ok)
+	return m.save()
+}
+
+// Delete removes key and reports whether it existed
+func (m *Map[V]) Delete(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; !ok {
+		return false, nil
+	}
+	delete(m.items, key)
+	return true, m.save()
+}
+
+// DeleteFunc removes every entry for which fn returns true and returns how many were removed
+func (m *Map[V]) DeleteFunc(fn func(key string, value V) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, value := range m.items {
+		if fn(key, value) {
+			delete(m.items, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, m.save()
+}
+
+// Keys returns all keys in sorted order
+func (m *Map[V]) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.items))
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns all values ordered by key
+func (m *Map[V]) Values() []V {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.items))
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]V, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, m.items[key])
+	}
+	return values
+}
+
+// Len returns the number of entries
+func (m *Map[V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.items)
+}
+
+// save writes the map to a temporary file and renames it into place.
+// Callers must hold m.mu.
+func (m *Map[V]) save() error {
+	data, err := json.MarshalIndent(m.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", m.path, err)
+	}
+
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("error replacing %s: %v", m.path, err)
+	}
+	return nil
+}
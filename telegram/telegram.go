@@ -0,0 +1,458 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"jellynotifier/events"
+	"jellynotifier/notifier"
+	"jellynotifier/retry"
+)
+
+// DefaultAPIURL is the public Telegram Bot API endpoint
+const DefaultAPIURL = "https://api.telegram.org"
+
+// Telegram message limits
+const (
+	maxCaptionLength = 1024
+	maxMessageLength = 4096
+)
+
+// Config holds the Telegram sink settings
+type Config struct {
+	// Token is the bot token issued by @BotFather
+	Token string
+	// ChatID is the group chat receiving every notification
+	ChatID string
+	// APIURL overrides the Bot API base URL, e.g. for a local fake server
+	APIURL string
+	// DMUsers also messages the requester, reporter or commenter at the
+	// telegramChatId carried in the payload
+	DMUsers bool
+}
+
+// Sink posts notifications through the Telegram Bot API
+type Sink struct {
+	cfg    Config
+	client *http.Client
+	policy retry.Policy
+}
+
+// NewSink creates a Telegram sink, retrying according to policy
+func NewSink(cfg Config, policy retry.Policy) (*Sink, error) {
+	log.Println("[DEBUG] [TELEGRAM] Creating new Telegram sink...")
+
+	if cfg.Token == "" {
+		log.Println("[ERROR] [TELEGRAM] Telegram bot token is empty")
+		return nil, fmt.Errorf("telegram bot token is required")
+	}
+	if cfg.ChatID == "" {
+		log.Println("[ERROR] [TELEGRAM] Telegram chat ID is empty")
+		return nil, fmt.Errorf("telegram chat ID is required")
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultAPIURL
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+
+	log.Printf("[DEBUG] [TELEGRAM] Target chat ID: %s, API URL: %s, DM users: %t", cfg.ChatID, cfg.APIURL, cfg.DMUsers)
+	return &Sink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 15 * time.Second},
+		policy: policy,
+	}, nil
+}
+
+// Name identifies the Telegram sink
+func (s *Sink) Name() string {
+	return "telegram"
+}
+
+// Capabilities describes the Telegram sink
+func (s *Sink) Capabilities() notifier.Capabilities {
+	return notifier.Capabilities{
+		Description:    fmt.Sprintf("Telegram bot posting to chat %s", s.cfg.ChatID),
+		Images:         true,
+		DirectMessages: s.cfg.DMUsers,
+	}
+}
+
+// Send posts the notification to the group chat and, when enabled, to the
+// users it concerns. Only a failure to reach the group chat fails the send;
+// DM failures (e.g. the user never started the bot) are logged.
+func (s *Sink) Send(ctx context.Context, event events.Event) error {
+	log.Printf("[DEBUG] [TELEGRAM] Preparing to send notification - Type: %s, Event: %s", event.Type, event.Kind)
+
+	msg := formatMessage(event)
+	if err := s.sendTo(ctx, s.cfg.ChatID, event.Image(), msg); err != nil {
+		log.Printf("[ERROR] [TELEGRAM] Failed to send message to chat %s: %v", s.cfg.ChatID, err)
+		return fmt.Errorf("error sending message to Telegram: %w", err)
+	}
+	log.Printf("Successfully sent notification to Telegram chat %s", s.cfg.ChatID)
+
+	if !s.cfg.DMUsers {
+		return nil
+	}
+	for _, chatID := range userChatIDs(event) {
+		if chatID == s.cfg.ChatID {
+			continue
+		}
+		if err := s.sendTo(ctx, chatID, event.Image(), msg); err != nil {
+			log.Printf("[WARN] [TELEGRAM] Failed to DM chat %s: %v", chatID, err)
+			continue
+		}
+		log.Printf("[DEBUG] [TELEGRAM] Sent DM to chat %s", chatID)
+	}
+	return nil
+}
+
+// Rendering is the Bot API call Send would make for one chat
+type Rendering struct {
+	Method  string                 `json:"method"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// NewRenderer creates a Sink that needs no bot token. It can Render
+// notifications for previews but must not be used to Send them.
+func NewRenderer(cfg Config) *Sink {
+	return &Sink{cfg: cfg}
+}
+
+// Render implements notifier.Renderer, returning the group chat request
+// and, when DMs are enabled, the DM requests
+func (s *Sink) Render(event events.Event) (interface{}, error) {
+	msg := formatMessage(event)
+	renderings := []Rendering{s.request(s.cfg.ChatID, event.Image(), msg)}
+	if s.cfg.DMUsers {
+		for _, chatID := range userChatIDs(event) {
+			if chatID != s.cfg.ChatID {
+				renderings = append(renderings, s.request(chatID, event.Image(), msg))
+			}
+		}
+	}
+	return renderings, nil
+}
+
+// request builds the Bot API call for one chat, using sendPhoto when there is a poster
+func (s *Sink) request(chatID, image string, msg message) Rendering {
+	method := "sendMessage"
+	payload := map[string]interface{}{
+		"chat_id":    chatID,
+		"parse_mode": "MarkdownV2",
+	}
+	if image != "" {
+		method = "sendPhoto"
+		payload["photo"] = image
+		payload["caption"] = msg.fit(maxCaptionLength)
+	} else {
+		payload["text"] = msg.fit(maxMessageLength)
+	}
+	return Rendering{Method: method, Payload: payload}
+}
+
+// sendTo posts to one chat. If Telegram cannot fetch the poster the
+// notification is sent again as a text message.
+func (s *Sink) sendTo(ctx context.Context, chatID, image string, msg message) error {
+	err := s.do(ctx, chatID, s.request(chatID, image, msg))
+	if image != "" && isPhotoError(err) {
+		log.Printf("[WARN] [TELEGRAM] Telegram could not use poster %s, sending a text message to chat %s instead: %v", image, chatID, err)
+		return s.do(ctx, chatID, s.request(chatID, "", msg))
+	}
+	return err
+}
+
+// do performs a Bot API call with retries
+func (s *Sink) do(ctx context.Context, chatID string, call Rendering) error {
+	method := call.Method
+
+	body, err := json.Marshal(call.Payload)
+	if err != nil {
+		return fmt.Errorf("error encoding Telegram request: %v", err)
+	}
+
+	return retry.Do(ctx, s.policy, func(attempt int) error {
+		log.Printf("[DEBUG] [TELEGRAM] Calling %s for chat %s (attempt %d)", method, chatID, attempt)
+		return s.call(ctx, method, body)
+	})
+}
+
+// apiResponse is the envelope returned by every Bot API method
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// call performs a single Bot API request and classifies failures for retry
+func (s *Sink) call(ctx context.Context, method string, body []byte) error {
+	url := fmt.Sprintf("%s/bot%s/%s", s.cfg.APIURL, s.cfg.Token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("error creating Telegram request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// Strip the URL, which contains the bot token
+		return fmt.Errorf("error calling Telegram %s: %v", method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var result apiResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return retry.CheckHTTPResponse(resp, raw)
+	}
+	if result.OK {
+		return nil
+	}
+
+	apiErr := &apiError{Method: method, Code: result.ErrorCode, Description: result.Description}
+	switch {
+	case result.ErrorCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTooManyRequests:
+		return retry.After(apiErr, time.Duration(result.Parameters.RetryAfter)*time.Second)
+	case resp.StatusCode >= 500:
+		return apiErr
+	default:
+		return retry.Permanent(apiErr)
+	}
+}
+
+// apiError is a failed Bot API call
+type apiError struct {
+	Method      string
+	Code        int
+	Description string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("telegram %s failed (%d): %s", e.Method, e.Code, e.Description)
+}
+
+// photoErrors are the descriptions Telegram gives when it cannot fetch or
+// use the photo URL passed to sendPhoto
+var photoErrors = []string{
+	"wrong file identifier/http url specified",
+	"failed to get http url content",
+	"wrong type of the web page content",
+	"webpage_curl_failed",
+	"webpage_media_empty",
+	"photo_invalid_dimensions",
+	"image_process_failed",
+}
+
+// isPhotoError reports whether err is a sendPhoto failure caused by the photo
+func isPhotoError(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.Method != "sendPhoto" || apiErr.Code != http.StatusBadRequest {
+		return false
+	}
+	description := strings.ToLower(apiErr.Description)
+	for _, photoErr := range photoErrors {
+		if strings.Contains(description, photoErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapURLError drops the request URL from transport errors
+func unwrapURLError(err error) error {
+	type unwrapper interface{ Unwrap() error }
+	if u, ok := err.(unwrapper); ok && u.Unwrap() != nil {
+		return u.Unwrap()
+	}
+	return err
+}
+
+// userChatIDs returns the distinct Telegram chat IDs of the users the notification concerns
+func userChatIDs(event events.Event) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range []string{
+		event.Requester().TelegramChatID,
+		event.Reporter().TelegramChatID,
+		event.Commenter().TelegramChatID,
+	} {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// message is a notification before it is assembled into MarkdownV2 text:
+// the title and body are plain text, the sections already escaped
+type message struct {
+	title    string
+	body     string
+	sections []string
+}
+
+// formatMessage splits the notification into a title, a body and MarkdownV2
+// detail sections
+func formatMessage(event events.Event) message {
+	var sections []string
+
+	if event.Media.Type != "" {
+		lines := []string{fmt.Sprintf("Type: %s", event.Media.Type)}
+		if event.Media.Status != "" {
+			lines = append(lines, fmt.Sprintf("Status: %s", event.Media.Status))
+		}
+		if event.Media.Status4k != "" {
+			lines = append(lines, fmt.Sprintf("4K Status: %s", event.Media.Status4k))
+		}
+		sections = append(sections, section("🎭 Media Info", lines))
+	}
+
+	if event.RequestID != "" && event.Requester().Name != "" {
+		sections = append(sections, section("📝 Request", []string{
+			fmt.Sprintf("Requested by: %s", event.Requester().Name),
+		}))
+	}
+
+	if event.Issue.ID != "" {
+		lines := []string{}
+		if event.Issue.Type != "" {
+			lines = append(lines, fmt.Sprintf("Type: %s", event.Issue.Type))
+		}
+		if event.Issue.Status != "" {
+			lines = append(lines, fmt.Sprintf("Status: %s", event.Issue.Status))
+		}
+		if event.Reporter().Name != "" {
+			lines = append(lines, fmt.Sprintf("Reported by: %s", event.Reporter().Name))
+		}
+		sections = append(sections, section("🐛 Issue", lines))
+	}
+
+	if event.Comment != "" {
+		lines := []string{event.Comment}
+		if event.Commenter().Name != "" {
+			lines = append(lines, fmt.Sprintf("By: %s", event.Commenter().Name))
+		}
+		sections = append(sections, section("💬 Comment", lines))
+	}
+
+	if details := event.Attributes; len(details) > 0 {
+		lines := make([]string, 0, len(details))
+		for _, detail := range details {
+			lines = append(lines, fmt.Sprintf("%s: %s", detail.Name, detail.Value))
+		}
+		sections = append(sections, section("ℹ️ Details", lines))
+	}
+
+	if event.Kind != "" {
+		sections = append(sections, "_"+escape(event.Kind)+"_")
+	}
+
+	return message{title: event.Title, body: event.Body, sections: sections}
+}
+
+// String assembles the MarkdownV2 text
+func (m message) String() string {
+	var parts []string
+	header := []string{}
+	if m.title != "" {
+		header = append(header, "*"+escape(m.title)+"*")
+	}
+	if m.body != "" {
+		header = append(header, escape(m.body))
+	}
+	if len(header) > 0 {
+		parts = append(parts, strings.Join(header, "\n"))
+	}
+	parts = append(parts, m.sections...)
+	return strings.Join(parts, "\n\n")
+}
+
+// fit assembles the text within n runes without cutting through an escape
+// or entity. The body is shortened as plain text before it is escaped; when
+// even no body is too long, whole sections are dropped from the end, and
+// as a last resort the title is shortened.
+func (m message) fit(n int) string {
+	fits := func() bool { return utf8.RuneCountInString(m.String()) <= n }
+
+	body := []rune(m.body)
+	for {
+		m.body = shorten(body, longest(len(body), func(i int) bool {
+			m.body = shorten(body, i)
+			return fits()
+		}))
+		if fits() || len(m.sections) == 0 {
+			break
+		}
+		m.sections = m.sections[:len(m.sections)-1]
+	}
+
+	if !fits() {
+		title := []rune(m.title)
+		m.title = shorten(title, longest(len(title), func(i int) bool {
+			m.title = shorten(title, i)
+			return fits()
+		}))
+	}
+	return m.String()
+}
+
+// longest returns the largest length up to max for which fits holds, or 0.
+// fits must hold for every length below one it holds for.
+func longest(max int, fits func(int) bool) int {
+	lo, hi := 0, max
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// shorten returns the first n runes, ending in an ellipsis when runes is longer
+func shorten(runes []rune, n int) string {
+	if n >= len(runes) {
+		return string(runes)
+	}
+	if n == 0 {
+		return ""
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// section renders a bold title followed by escaped lines
+func section(title string, lines []string) string {
+	escaped := make([]string, 0, len(lines)+1)
+	escaped = append(escaped, "*"+escape(title)+"*")
+	for _, line := range lines {
+		escaped = append(escaped, escape(line))
+	}
+	return strings.Join(escaped, "\n")
+}
+
+// markdownV2Replacer escapes every character reserved by MarkdownV2
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// escape makes s safe to embed in MarkdownV2 text
+func escape(s string) string {
+	return markdownV2Replacer.Replace(s)
+}
@@ -0,0 +1,315 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"jellynotifier/events"
+	"jellynotifier/retry"
+)
+
+// reply is one canned Bot API response
+type reply struct {
+	status int
+	body   string
+}
+
+// call is a Bot API request received by the fake server
+type call struct {
+	method  string
+	payload map[string]interface{}
+}
+
+// botAPI is a stand-in Bot API answering with the given replies in turn,
+// then success
+type botAPI struct {
+	*httptest.Server
+	mu      sync.Mutex
+	replies []reply
+	calls   []call
+}
+
+func newBotAPI(t *testing.T, replies ...reply) *botAPI {
+	api := &botAPI{replies: replies}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (a *botAPI) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	method := strings.TrimPrefix(r.URL.Path, "/bottoken/")
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.calls = append(a.calls, call{method: method, payload: payload})
+
+	next := reply{status: http.StatusOK, body: `{"ok":true,"result":{}}`}
+	if len(a.replies) > 0 {
+		next, a.replies = a.replies[0], a.replies[1:]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(next.status)
+	w.Write([]byte(next.body))
+}
+
+func (a *botAPI) received() []call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]call(nil), a.calls...)
+}
+
+// fastPolicy retries quickly so tests do not wait on backoff
+var fastPolicy = retry.Policy{
+	MaxAttempts:     3,
+	InitialInterval: time.Millisecond,
+	MaxInterval:     time.Millisecond,
+	MaxElapsed:      10 * time.Second,
+	Multiplier:      1,
+}
+
+func newTestSink(t *testing.T, api *botAPI, dmUsers bool) *Sink {
+	t.Helper()
+	sink, err := NewSink(Config{Token: "token", ChatID: "-100", APIURL: api.URL + "/", DMUsers: dmUsers}, fastPolicy)
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+	return sink
+}
+
+func TestSendChoosesMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  string
+		method string
+		field  string
+	}{
+		{name: "poster", image: "https://image.example/poster.jpg", method: "sendPhoto", field: "caption"},
+		{name: "no poster", method: "sendMessage", field: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newBotAPI(t)
+			event := events.Event{Kind: "media.available", Title: "Dune"}
+			event.AddImage(events.ImagePoster, tt.image)
+
+			if err := newTestSink(t, api, false).Send(context.Background(), event); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			calls := api.received()
+			if len(calls) != 1 || calls[0].method != tt.method {
+				t.Fatalf("calls = %+v, want one %s", calls, tt.method)
+			}
+			payload := calls[0].payload
+			if payload["chat_id"] != "-100" || payload["parse_mode"] != "MarkdownV2" {
+				t.Errorf("payload = %v", payload)
+			}
+			if _, ok := payload[tt.field].(string); !ok {
+				t.Errorf("payload has no %s: %v", tt.field, payload)
+			}
+			if tt.image != "" && payload["photo"] != tt.image {
+				t.Errorf("photo = %v, want %s", payload["photo"], tt.image)
+			}
+		})
+	}
+}
+
+func TestSendFallsBackWhenThePosterFails(t *testing.T) {
+	api := newBotAPI(t, reply{http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`})
+	event := events.Event{Kind: "media.available", Title: "Dune"}
+	event.AddImage(events.ImagePoster, "https://image.example/missing.jpg")
+
+	if err := newTestSink(t, api, false).Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	calls := api.received()
+	if len(calls) != 2 || calls[0].method != "sendPhoto" || calls[1].method != "sendMessage" {
+		t.Fatalf("calls = %+v, want sendPhoto then sendMessage", calls)
+	}
+	if text := calls[1].payload["text"]; text != "*Dune*\n\n_media\\.available_" {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestFormatMessageEscapes(t *testing.T) {
+	event := events.Event{
+		Kind:      "media.available",
+		Title:     "Dune: Part Two (2024)",
+		Body:      "Rated 8.5/10 - *must* watch!",
+		Media:     events.Media{Type: "movie", Status: "AVAILABLE"},
+		RequestID: "42",
+	}
+	event.AddActor(events.Actor{Role: events.RoleRequester, Name: "alice_b"})
+
+	want := strings.Join([]string{
+		"*Dune: Part Two \\(2024\\)*\nRated 8\\.5/10 \\- \\*must\\* watch\\!",
+		"*🎭 Media Info*\nType: movie\nStatus: AVAILABLE",
+		"*📝 Request*\nRequested by: alice\\_b",
+		"_media\\.available_",
+	}, "\n\n")
+	if got := formatMessage(event).String(); got != want {
+		t.Errorf("text =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFitShortensTheBody(t *testing.T) {
+	event := events.Event{
+		Kind:  "issue.created",
+		Title: "Dune",
+		Body:  strings.Repeat("a.b_", 400),
+		Issue: events.Issue{ID: "3", Type: "Audio"},
+	}
+	msg := formatMessage(event)
+
+	caption := msg.fit(maxCaptionLength)
+	if n := utf8.RuneCountInString(caption); n > maxCaptionLength {
+		t.Fatalf("caption is %d runes, over %d", n, maxCaptionLength)
+	}
+	if !strings.HasSuffix(caption, "…\n\n*🐛 Issue*\nType: Audio\n\n_issue\\.created_") {
+		t.Errorf("caption does not keep the sections after the shortened body:\n%s", caption)
+	}
+	if strings.Contains(caption, "\\…") {
+		t.Errorf("caption cuts through an escape:\n%s", caption)
+	}
+	if text := msg.fit(maxMessageLength); text != msg.String() {
+		t.Errorf("text within the message limit was changed")
+	}
+}
+
+func TestFitDropsTrailingSections(t *testing.T) {
+	event := events.Event{Kind: "issue.comment", Title: "Dune", Body: "New comment", Comment: strings.Repeat("*", 600)}
+
+	caption := formatMessage(event).fit(maxCaptionLength)
+	if caption != "*Dune*\nNew comment" {
+		t.Errorf("caption = %q, want the comment and kind dropped", caption)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []reply
+		requests  int
+		wantErr   bool
+		permanent bool
+		minWait   time.Duration
+	}{
+		{
+			name:     "server error then success",
+			replies:  []reply{{http.StatusBadGateway, `<html>Bad Gateway</html>`}, {http.StatusInternalServerError, `{"ok":false,"error_code":500,"description":"Internal Server Error"}`}},
+			requests: 3,
+		},
+		{
+			name:     "rate limited honours retry_after",
+			replies:  []reply{{http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`}},
+			requests: 2,
+			minWait:  time.Second,
+		},
+		{
+			name:     "server errors exhaust the policy",
+			replies:  []reply{{500, `{"ok":false,"error_code":500}`}, {500, `{"ok":false,"error_code":500}`}, {500, `{"ok":false,"error_code":500}`}},
+			requests: 3,
+			wantErr:  true,
+		},
+		{
+			name:      "client error is not retried",
+			replies:   []reply{{http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`}},
+			requests:  1,
+			wantErr:   true,
+			permanent: true,
+		},
+		{
+			name:      "forbidden is not retried",
+			replies:   []reply{{http.StatusForbidden, `{"ok":false,"error_code":403,"description":"Forbidden: bot was kicked from the group chat"}`}},
+			requests:  1,
+			wantErr:   true,
+			permanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newBotAPI(t, tt.replies...)
+
+			started := time.Now()
+			err := newTestSink(t, api, false).Send(context.Background(), events.Event{Kind: "media.available", Title: "Dune"})
+			if elapsed := time.Since(started); elapsed < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", elapsed, tt.minWait)
+			}
+			if n := len(api.received()); n != tt.requests {
+				t.Errorf("Bot API got %d request(s), want %d", n, tt.requests)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var retryErr *retry.Error
+			if !errors.As(err, &retryErr) {
+				t.Fatalf("error = %v, want a *retry.Error", err)
+			}
+			if retryErr.Permanent != tt.permanent || retryErr.Attempts != tt.requests {
+				t.Errorf("got %d attempt(s), permanent %t; want %d, %t", retryErr.Attempts, retryErr.Permanent, tt.requests, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestSendMessagesUsers(t *testing.T) {
+	event := events.Event{Kind: "issue.comment", Title: "Dune", Comment: "Still broken"}
+	event.AddActor(events.Actor{Role: events.RoleRequester, Name: "alice", TelegramChatID: "1"})
+	event.AddActor(events.Actor{Role: events.RoleReporter, Name: "bob", TelegramChatID: "2"})
+	event.AddActor(events.Actor{Role: events.RoleCommenter, Name: "carol", TelegramChatID: "-100"})
+
+	tests := []struct {
+		name    string
+		dmUsers bool
+		chats   []string
+	}{
+		{name: "DMs enabled", dmUsers: true, chats: []string{"-100", "1", "2"}},
+		{name: "DMs disabled", chats: []string{"-100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newBotAPI(t)
+			if err := newTestSink(t, api, tt.dmUsers).Send(context.Background(), event); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			var chats []string
+			for _, c := range api.received() {
+				chats = append(chats, c.payload["chat_id"].(string))
+			}
+			if strings.Join(chats, " ") != strings.Join(tt.chats, " ") {
+				t.Errorf("sent to chats %v, want %v", chats, tt.chats)
+			}
+		})
+	}
+}
+
+func TestSendIgnoresFailedDMs(t *testing.T) {
+	api := newBotAPI(t, reply{http.StatusOK, `{"ok":true,"result":{}}`}, reply{http.StatusForbidden, `{"ok":false,"error_code":403,"description":"Forbidden: bot can't initiate conversation with a user"}`})
+	event := events.Event{Kind: "media.available", Title: "Dune", RequestID: "42"}
+	event.AddActor(events.Actor{Role: events.RoleRequester, Name: "alice", TelegramChatID: "1"})
+
+	if err := newTestSink(t, api, true).Send(context.Background(), event); err != nil {
+		t.Errorf("Send failed on a DM: %v", err)
+	}
+	if n := len(api.received()); n != 2 {
+		t.Errorf("Bot API got %d request(s), want 2", n)
+	}
+}
@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Funcs returns the helper functions available to every template:
+//
+//	truncate N S     shortens S to N runes, ending in "…"
+//	default D S      S, or D when S is empty
+//	lower S          S in lower case
+//	join SEP LIST    joins a list of strings (or values) with SEP
+//	tmdbURL TYPE ID  the TMDB page for a "movie" or "tv" ID
+//	field NAME VALUE [INLINE]  adds an embed field (only in "fields")
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"truncate": truncate,
+		"default":  defaultValue,
+		"lower":    strings.ToLower,
+		"join":     join,
+		"tmdbURL":  TMDBURL,
+	}
+}
+
+// truncate shortens s to at most n runes
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// defaultValue returns fallback when value is empty, for use as {{.X | default "n/a"}}
+func defaultValue(fallback, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
+
+// join joins strings, or the formatted values of any other list
+func join(sep string, list interface{}) string {
+	switch items := list.(type) {
+	case []string:
+		return strings.Join(items, sep)
+	case []interface{}:
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, sep)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(items)
+	}
+}
+
+// TMDBURL returns the TMDB page of a movie or TV show, or "" without an ID
+func TMDBURL(mediaType, id string) string {
+	if id == "" {
+		return ""
+	}
+	kind := "movie"
+	if strings.EqualFold(mediaType, "tv") {
+		kind = "tv"
+	}
+	return fmt.Sprintf("https://www.themoviedb.org/%s/%s", kind, id)
+}
@@ -0,0 +1,240 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"jellynotifier/events"
+)
+
+// Extension is the file extension of template files
+const Extension = ".tmpl"
+
+// DefaultName is the template used for events without their own file
+const DefaultName = "default"
+
+// Sections lists the named templates a file may define. Any section a file
+// leaves out is rendered empty, and an empty color or thumbnail falls back
+// to the built-in choice.
+var Sections = []string{"title", "description", "fields", "footer", "color", "thumbnail"}
+
+// Field is a rendered embed field
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Message is the sink-neutral result of rendering a template
+type Message struct {
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Fields      []Field `json:"fields,omitempty"`
+	Footer      string  `json:"footer,omitempty"`
+	// Color is 0 when the template leaves the choice to the sink
+	Color     int    `json:"color,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+// Set holds the parsed templates, keyed by event name
+type Set struct {
+	dir       string
+	templates map[string]*template.Template
+}
+
+// Load parses every <event>.tmpl file in dir, e.g. media.available.tmpl,
+// plus an optional default.tmpl used for every other event. Each template is
+// also executed against an empty event so that references to
+// unknown fields fail at startup rather than on the first webhook. A
+// missing directory yields an empty set.
+func Load(dir string) (*Set, error) {
+	set := &Set{dir: dir, templates: make(map[string]*template.Template)}
+	if dir == "" {
+		return set, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		return nil, fmt.Errorf("error listing templates in %s: %v", dir, err)
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			log.Printf("[DEBUG] [TEMPLATES] Template directory %s does not exist, using built-in layouts", dir)
+			return set, nil
+		}
+	}
+
+	for _, file := range files {
+		event := strings.TrimSuffix(filepath.Base(file), Extension)
+		tmpl, err := parse(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := render(tmpl, events.Event{Kind: event}); err != nil {
+			return nil, fmt.Errorf("template %s: %v", file, err)
+		}
+		set.templates[strings.ToLower(event)] = tmpl
+		log.Printf("[DEBUG] [TEMPLATES] Loaded template for %s from %s", event, file)
+	}
+
+	log.Printf("[DEBUG] [TEMPLATES] Loaded %d template(s) from %s", len(set.templates), dir)
+	return set, nil
+}
+
+// parse reads one template file and checks it only defines known sections
+func parse(file string) (*template.Template, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %v", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(file)).
+		Option("missingkey=error").
+		Funcs(Funcs()).
+		Funcs(template.FuncMap{"field": func(string, string, ...bool) string { return "" }}).
+		Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, defined := range tmpl.Templates() {
+		name := defined.Name()
+		if name == tmpl.Name() {
+			continue
+		}
+		if !isSection(name) {
+			return nil, fmt.Errorf("template %s defines unknown section %q, expected one of %s", file, name, strings.Join(Sections, ", "))
+		}
+	}
+	return tmpl, nil
+}
+
+// Len returns the number of loaded templates
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.templates)
+}
+
+// Events returns the events that have their own template, sorted
+func (s *Set) Events() []string {
+	if s == nil {
+		return nil
+	}
+	events := make([]string, 0, len(s.templates))
+	for event := range s.templates {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Render renders the template for the event's kind, or the default
+// template. It reports false when neither exists so the caller can use its
+// built-in layout.
+func (s *Set) Render(event events.Event) (*Message, bool, error) {
+	if s == nil {
+		return nil, false, nil
+	}
+	tmpl, ok := s.templates[strings.ToLower(event.Kind)]
+	if !ok {
+		if tmpl, ok = s.templates[DefaultName]; !ok {
+			return nil, false, nil
+		}
+	}
+	message, err := render(tmpl, event)
+	if err != nil {
+		return nil, true, fmt.Errorf("error rendering template %s: %v", tmpl.Name(), err)
+	}
+	return message, true, nil
+}
+
+// render executes every section of tmpl against the event
+func render(tmpl *template.Template, event events.Event) (*Message, error) {
+	// Each render gets its own clone so "field" can collect into a local slice
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	message := &Message{}
+	clone.Funcs(template.FuncMap{
+		"field": func(name, value string, inline ...bool) string {
+			if strings.TrimSpace(value) != "" {
+				message.Fields = append(message.Fields, Field{Name: name, Value: value, Inline: len(inline) > 0 && inline[0]})
+			}
+			return ""
+		},
+	})
+
+	section := func(name string) (string, error) {
+		if clone.Lookup(name) == nil {
+			return "", nil
+		}
+		var buf bytes.Buffer
+		if err := clone.ExecuteTemplate(&buf, name, event); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+
+	if message.Title, err = section("title"); err != nil {
+		return nil, err
+	}
+	if message.Description, err = section("description"); err != nil {
+		return nil, err
+	}
+	if _, err = section("fields"); err != nil {
+		return nil, err
+	}
+	if message.Footer, err = section("footer"); err != nil {
+		return nil, err
+	}
+	if message.Thumbnail, err = section("thumbnail"); err != nil {
+		return nil, err
+	}
+	color, err := section("color")
+	if err != nil {
+		return nil, err
+	}
+	if message.Color, err = parseColor(color); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// parseColor accepts "#RRGGBB", "0xRRGGBB" or a decimal colour; empty is 0
+func parseColor(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "#"):
+		s, base = s[1:], 16
+	case strings.HasPrefix(strings.ToLower(s), "0x"):
+		s, base = s[2:], 16
+	}
+	color, err := strconv.ParseInt(s, base, 32)
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("invalid color %q", s)
+	}
+	return int(color), nil
+}
+
+// isSection reports whether name is a known section
+func isSection(name string) bool {
+	for _, section := range Sections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}